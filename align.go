@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+func runAlign(args []string) {
+	fs := flag.NewFlagSet("align", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes align FILE...")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Align the changes in patch notes files of different locales and record")
+		fmt.Fprintln(fs.Output(), "the translations of each change in all files.")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var files []*localizedNotes
+	seen := map[string]string{}
+	for _, fname := range fs.Args() {
+		notes, err := readPatchNotes(fname)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ln := &localizedNotes{fname: fname, notes: notes, locale: notesLocale(notes)}
+		if other, ok := seen[ln.locale]; ok {
+			log.Fatalf("%s and %s both have locale %s", other, fname, ln.locale)
+		}
+		seen[ln.locale] = fname
+
+		files = append(files, ln)
+	}
+
+	alignLocales(files)
+
+	for _, ln := range files {
+		if err := writePatchNotes(ln.fname, ln.notes); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+type localizedNotes struct {
+	fname  string
+	locale string
+	notes  *PatchNotes
+}
+
+// notesLocale returns the locale of the articles in notes.
+func notesLocale(notes *PatchNotes) string {
+	for _, c := range notes.Changes {
+		if u, err := url.Parse(c.URL); err == nil {
+			return localeOf(u).Name
+		}
+	}
+	return defaultLocale
+}
+
+// alignKey identifies the changes of one article on one date, independent of
+// the locale.
+type alignKey struct {
+	Article string
+	Date    string
+}
+
+// alignLocales matches changes across locales and records the text of each
+// counterpart in Change.Translations. Changes are matched by article and date
+// first, and then by their position within the article, which mirrors their
+// position in the Tree. Tags are mapped to English where possible, so runs of
+// changes with the same tags help to align articles whose structure differs
+// slightly between locales.
+func alignLocales(files []*localizedNotes) {
+	groups := make([]map[alignKey][]*Change, len(files))
+	for i, ln := range files {
+		groups[i] = map[alignKey][]*Change{}
+		for j := range ln.notes.Changes {
+			c := &ln.notes.Changes[j]
+			c.Translations = nil
+
+			k := alignKey{Article: path.Base(c.URL), Date: c.Date}
+			groups[i][k] = append(groups[i][k], c)
+		}
+	}
+
+	var aligned, unaligned int
+	for i := range files {
+		for j := i + 1; j < len(files); j++ {
+			for k, as := range groups[i] {
+				bs, ok := groups[j][k]
+				if !ok {
+					continue
+				}
+
+				pairs := alignGroup(as, bs)
+				for _, p := range pairs {
+					a, b := as[p[0]], bs[p[1]]
+					setTranslation(a, files[j].locale, b.Text)
+					setTranslation(b, files[i].locale, a.Text)
+				}
+
+				n := len(as)
+				if len(bs) > n {
+					n = len(bs)
+				}
+				aligned += len(pairs)
+				unaligned += n - len(pairs)
+			}
+		}
+	}
+
+	log.Printf("aligned %d changes, %d could not be aligned", aligned, unaligned)
+}
+
+func setTranslation(c *Change, locale, text string) {
+	if c.Translations == nil {
+		c.Translations = map[string]string{}
+	}
+	c.Translations[locale] = text
+}
+
+// alignGroup aligns the changes of a single article and date in two locales.
+// It returns pairs of indexes into as and bs.
+func alignGroup(as, bs []*Change) [][2]int {
+	ra, rb := tagRuns(as), tagRuns(bs)
+
+	// Same tags in the same order; align by position.
+	if sameRuns(ra, rb) {
+		pairs := make([][2]int, len(as))
+		for i := range as {
+			pairs[i] = [2]int{i, i}
+		}
+		return pairs
+	}
+
+	// Same number of runs; align runs by position, skipping runs that
+	// differ in length. Runs with different tags are only aligned if
+	// neither tags appear in the other locale, i.e. if they are probably
+	// the same tags, just not translated to English.
+	var pairs [][2]int
+	if len(ra) == len(rb) {
+		for i := range ra {
+			if ra[i].tags == rb[i].tags || !hasRun(rb, ra[i].tags) && !hasRun(ra, rb[i].tags) {
+				pairs = appendRunPairs(pairs, ra[i], rb[i])
+			}
+		}
+		return pairs
+	}
+
+	// Different structure; only align runs whose tags match exactly.
+	j := 0
+	for _, r := range ra {
+		for k := j; k < len(rb); k++ {
+			if r.tags == rb[k].tags {
+				pairs = appendRunPairs(pairs, r, rb[k])
+				j = k + 1
+				break
+			}
+		}
+	}
+
+	return pairs
+}
+
+type tagRun struct {
+	tags       string
+	start, end int
+}
+
+func tagRuns(cs []*Change) []tagRun {
+	var runs []tagRun
+	for i, c := range cs {
+		tags := strings.Join(c.Tags, "\x00")
+		if n := len(runs); n > 0 && runs[n-1].tags == tags {
+			runs[n-1].end = i + 1
+			continue
+		}
+		runs = append(runs, tagRun{tags: tags, start: i, end: i + 1})
+	}
+	return runs
+}
+
+func appendRunPairs(pairs [][2]int, a, b tagRun) [][2]int {
+	if a.end-a.start != b.end-b.start {
+		return pairs
+	}
+	for i := 0; i < a.end-a.start; i++ {
+		pairs = append(pairs, [2]int{a.start + i, b.start + i})
+	}
+	return pairs
+}
+
+func sameRuns(a, b []tagRun) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].tags != b[i].tags || a[i].end-a[i].start != b[i].end-b[i].start {
+			return false
+		}
+	}
+	return true
+}
+
+func hasRun(runs []tagRun, tags string) bool {
+	for _, r := range runs {
+		if r.tags == tags {
+			return true
+		}
+	}
+	return false
+}
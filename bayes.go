@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BayesModel is a multinomial naive Bayes model that tells tags from changes.
+// It is trained from existing patch notes files with the train command.
+type BayesModel struct {
+	// Locales lists the locales of the training data. The model is not
+	// used for other locales.
+	Locales []string
+	Classes map[TextType]*BayesClass
+	// Vocabulary is the number of distinct features in the training data.
+	Vocabulary int
+}
+
+type BayesClass struct {
+	Docs   int            // number of training texts
+	Tokens int            // number of features in all training texts
+	Counts map[string]int // feature -> number of occurrences
+}
+
+// bayesFeatures returns the words of text and a few features describing its
+// shape, like its length and punctuation.
+func bayesFeatures(text string) []string {
+	var fs []string
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+	for _, w := range words {
+		fs = append(fs, "w:"+w)
+	}
+
+	n := len(words)
+	switch {
+	case n <= 1:
+		fs = append(fs, "words:1")
+	case n <= 3:
+		fs = append(fs, "words:2-3")
+	case n <= 6:
+		fs = append(fs, "words:4-6")
+	default:
+		fs = append(fs, "words:7+")
+	}
+
+	// Tags are title case, changes are sentences.
+	var upper int
+	for _, w := range strings.Fields(text) {
+		if r := []rune(w)[0]; unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	if 2*upper >= len(strings.Fields(text)) {
+		fs = append(fs, "case:title")
+	} else {
+		fs = append(fs, "case:sentence")
+	}
+
+	if strings.ContainsAny(text, "0123456789") {
+		fs = append(fs, "has:digit")
+	}
+	for _, p := range []string{".", ",", "%", "(", "!", "?"} {
+		if strings.Contains(text, p) {
+			fs = append(fs, "has:"+p)
+		}
+	}
+	if strings.HasSuffix(text, ":") {
+		fs = append(fs, "end::")
+	}
+
+	return fs
+}
+
+func newBayesModel() *BayesModel {
+	return &BayesModel{
+		Classes: map[TextType]*BayesClass{
+			TypeTag:    {Counts: map[string]int{}},
+			TypeChange: {Counts: map[string]int{}},
+		},
+	}
+}
+
+func (m *BayesModel) add(text string, typ TextType) {
+	c := m.Classes[typ]
+	c.Docs++
+	for _, f := range bayesFeatures(text) {
+		c.Tokens++
+		c.Counts[f]++
+	}
+}
+
+func (m *BayesModel) finish() {
+	vocab := map[string]bool{}
+	for _, c := range m.Classes {
+		for f := range c.Counts {
+			vocab[f] = true
+		}
+	}
+	m.Vocabulary = len(vocab)
+}
+
+// Predict returns the more likely type of text, and its probability.
+//
+// Both types are assumed to be equally likely a priori. There are far more
+// changes than tags in the training data, but the texts the model is used for
+// are short and mostly tags.
+func (m *BayesModel) Predict(text string) (TextType, float64) {
+	fs := bayesFeatures(text)
+	logP := map[TextType]float64{}
+	for typ, c := range m.Classes {
+		var p float64
+		for _, f := range fs {
+			p += math.Log(float64(c.Counts[f]+1) / float64(c.Tokens+m.Vocabulary))
+		}
+		logP[typ] = p
+	}
+
+	// Normalize, i.e. P(change) = 1 / (1 + exp(log P(tag) - log P(change))).
+	pChange := 1 / (1 + math.Exp(logP[TypeTag]-logP[TypeChange]))
+	if pChange >= 0.5 {
+		return TypeChange, pChange
+	}
+	return TypeTag, 1 - pChange
+}
+
+func readBayesModel(fname string) (*BayesModel, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &BayesModel{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	if m.Classes[TypeTag] == nil || m.Classes[TypeChange] == nil {
+		return nil, fmt.Errorf("%s: want classes %q and %q", fname, TypeTag, TypeChange)
+	}
+
+	return m, nil
+}
+
+// BayesClassifier uses a BayesModel where Base is uncertain, i.e. where the
+// HeuristicClassifier only decides by its default rule. The model overrides
+// Base only if it is at least MinProbability sure.
+type BayesClassifier struct {
+	Model          *BayesModel
+	Base           Classifier
+	MinProbability float64
+}
+
+func (c *BayesClassifier) Classify(t *Tree, loc *Locale) Classification {
+	base := c.Base.Classify(t, loc)
+	if base.Rule != ruleDefault || !sliceContains(c.Model.Locales, loc.Name) {
+		return base
+	}
+
+	predicted, p := c.Model.Predict(t.Text)
+	if predicted != base.Type && p < c.MinProbability {
+		return Classification{base.Type, fmt.Sprintf("%s (bayes: %s)", base.Rule, predicted), 1 - p}
+	}
+	return Classification{predicted, "bayes", p}
+}
+
+// bayesMinProbability is the MinProbability of the BayesClassifier.
+const bayesMinProbability = 0.9
+
+func runTrain(args []string) {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes train [flags] [FILE...]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Train the model of the naive Bayes classifier from the tags and changes in")
+		fmt.Fprintln(fs.Output(), "patch notes files. Defaults to the files of the default locale in site/.")
+		fs.PrintDefaults()
+	}
+
+	var modelFile string
+	var holdout int
+	fs.StringVar(&modelFile, "o", "model.json", "Write the model to this file.")
+	fs.IntVar(&holdout, "holdout", 10,
+		"Report the accuracy on every n-th text, using a model trained without them.")
+
+	fs.Parse(args)
+
+	fnames := fs.Args()
+	if len(fnames) == 0 {
+		all, err := filepath.Glob("site/wow-*-patch-notes.json")
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, fname := range all {
+			if m := siteFilePattern.FindStringSubmatch(filepath.Base(fname)); m != nil && m[2] == "" {
+				fnames = append(fnames, fname)
+			}
+		}
+	}
+
+	// Every distinct text is used once; tags repeat for every change and
+	// would otherwise drown out everything else.
+	texts := map[string]TextType{}
+	seenLocales := map[string]bool{}
+	for _, fname := range fnames {
+		notes, err := readPatchNotes(fname)
+		if err != nil {
+			log.Fatalf("%s: %v", fname, err)
+		}
+
+		for _, c := range notes.Changes {
+			if u, err := url.Parse(c.URL); err == nil && c.URL != "" {
+				seenLocales[localeOf(u).Name] = true
+			}
+			for _, t := range c.Tags {
+				if !versionPattern.MatchString(t) {
+					texts[t] = TypeTag
+				}
+			}
+		}
+		for _, c := range notes.Changes {
+			if _, ok := texts[c.Text]; !ok {
+				texts[c.Text] = TypeChange
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(texts))
+	for t := range texts {
+		keys = append(keys, t)
+	}
+	sort.Strings(keys)
+
+	model, partial := newBayesModel(), newBayesModel()
+	for i, t := range keys {
+		model.add(t, texts[t])
+		if holdout <= 0 || i%holdout != 0 {
+			partial.add(t, texts[t])
+		}
+	}
+	model.finish()
+	partial.finish()
+
+	for l := range seenLocales {
+		model.Locales = append(model.Locales, l)
+	}
+	sort.Strings(model.Locales)
+
+	log.Printf("trained on %d tags and %d changes", model.Classes[TypeTag].Docs, model.Classes[TypeChange].Docs)
+
+	if holdout > 0 {
+		// Also report how the classifier fares on the texts it is actually
+		// used for, i.e. those the heuristics are uncertain about. Note that
+		// the training data has been labelled by the heuristics in the first
+		// place, so they are right about all of them by definition.
+		loc := locales[defaultLocale]
+		partial.Locales = []string{loc.Name}
+		c := &BayesClassifier{Model: partial, Base: HeuristicClassifier{}, MinProbability: bayesMinProbability}
+
+		var n, correct, un, ucorrect int
+		for i := 0; i < len(keys); i += holdout {
+			n++
+			if typ, _ := partial.Predict(keys[i]); typ == texts[keys[i]] {
+				correct++
+			}
+
+			t := &Tree{Text: keys[i]}
+			if (HeuristicClassifier{}).Classify(t, loc).Rule != ruleDefault {
+				continue
+			}
+			un++
+			if c.Classify(t, loc).Type == texts[keys[i]] {
+				ucorrect++
+			}
+		}
+		log.Printf("held-out accuracy of the model: %d/%d (%.1f%%)", correct, n, 100*float64(correct)/float64(n))
+		if un > 0 {
+			log.Printf("held-out accuracy where the heuristics are uncertain: %d/%d (%.1f%%)", ucorrect, un, 100*float64(ucorrect)/float64(un))
+		}
+	}
+
+	b, err := json.Marshal(model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := writeFileAtomic(modelFile, append(b, '\n')); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Calendar lists the release dates of retail patches. It is read from
+// patches.json.
+type Calendar struct {
+	Patches []*Patch
+}
+
+type Patch struct {
+	Version string
+	// Release maps regions ("us", "eu", ...) to the release date in that
+	// region.
+	Release map[string]string
+	// Site is the key of the site/wow-<key>-patch-notes.json file that
+	// changes for this patch are written to. It defaults to the major and
+	// minor version, e.g. "10.2" for 10.2.5.
+	Site string `json:",omitempty"`
+	// SiteFrom is the first day whose hotfixes are written to the Site file
+	// of this patch, if that is not the release date (YYYY-MM-DD, in all
+	// regions). It keeps the split of existing files intact.
+	SiteFrom string `json:",omitempty"`
+
+	date     time.Time // in the selected region
+	siteFrom time.Time
+}
+
+// SiteKey returns the key of the site file for this patch.
+func (p *Patch) SiteKey() string {
+	if p.Site != "" {
+		return p.Site
+	}
+
+	parts := strings.SplitN(p.Version, ".", 3)
+	if len(parts) < 2 {
+		return p.Version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// readCalendar reads the calendar in fname, using the release dates of the
+// given region.
+func readCalendar(fname, region string) (*Calendar, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Calendar{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+
+	for _, p := range c.Patches {
+		d, ok := p.Release[region]
+		if !ok {
+			return nil, fmt.Errorf("%s: %s: no release date for region %q", fname, p.Version, region)
+		}
+
+		p.date, err = time.Parse(time.DateOnly, d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", fname, p.Version, err)
+		}
+
+		p.siteFrom = p.date
+		if p.SiteFrom != "" {
+			p.siteFrom, err = time.Parse(time.DateOnly, p.SiteFrom)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", fname, p.Version, err)
+			}
+		}
+	}
+
+	sort.SliceStable(c.Patches, func(i, j int) bool {
+		return c.Patches[i].date.Before(c.Patches[j].date)
+	})
+
+	return c, nil
+}
+
+// PatchAt returns the patch that was live on the given day.
+func (c *Calendar) PatchAt(t time.Time) (*Patch, bool) {
+	var live *Patch
+	for _, p := range c.Patches {
+		if p.date.After(t) {
+			break
+		}
+		live = p
+	}
+	return live, live != nil
+}
+
+// SitePatchAt returns the patch whose site file the hotfixes of the given day
+// are written to. It is the patch that was live on that day, unless SiteFrom
+// says otherwise.
+func (c *Calendar) SitePatchAt(t time.Time) (*Patch, bool) {
+	var live *Patch
+	for _, p := range c.Patches {
+		if !p.siteFrom.After(t) && (live == nil || !p.siteFrom.Before(live.siteFrom)) {
+			live = p
+		}
+	}
+	return live, live != nil
+}
+
+// Patch returns the patch with the given version.
+func (c *Calendar) Patch(version string) (*Patch, bool) {
+	for _, p := range c.Patches {
+		if p.Version == version {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// ReleaseDate returns the release date of the given patch version.
+func (c *Calendar) ReleaseDate(version string) (time.Time, bool) {
+	if p, ok := c.Patch(version); ok {
+		return p.date, true
+	}
+	return time.Time{}, false
+}
+
+// assignVersions sets the version of retail changes. The notes of content
+// updates, which are usually published before the patch is released, are
+// tagged with their version. All other changes belong to the patch that was
+// live on the day of the change. Their version is computed again on every run,
+// so that fixes to the calendar apply to changes that have been written before.
+func (c *Calendar) assignVersions(changes []Change) {
+	for i, ch := range changes {
+		if ch.Flavor != "" && ch.Flavor != FlavorRetail {
+			continue
+		}
+
+		if v := versionTag(ch.Tags); v != "" {
+			changes[i].Version = v
+			continue
+		}
+
+		changes[i].Version = ""
+		date, err := time.Parse(time.DateOnly, ch.Date)
+		if err != nil {
+			continue
+		}
+
+		if p, ok := c.PatchAt(date); ok {
+			changes[i].Version = p.Version
+		}
+	}
+}
+
+// versionPattern matches the version tags of content updates, e.g. "10.2.7".
+var versionPattern = regexp.MustCompile(`^\d+(\.\d+)+$`)
+
+// versionTag returns the first tag that is a patch version, if any.
+func versionTag(tags []string) string {
+	for _, t := range tags {
+		if versionPattern.MatchString(t) {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func readTestCalendar(t *testing.T) *Calendar {
+	t.Helper()
+
+	calendar, err := readCalendar("patches.json", "us")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calendar
+}
+
+func TestSitePatchAt(t *testing.T) {
+	calendar := readTestCalendar(t)
+
+	tests := []struct {
+		date string
+		live string // PatchAt
+		site string // SitePatchAt
+	}{
+		{"2022-10-01", "", ""},
+		{"2022-10-25", "10.0.0", "10.0"},
+		{"2023-05-01", "10.0.7", "10.0"},
+		{"2023-05-02", "10.1.0", "10.1"},
+		{"2024-04-16", "10.2.6", "10.2"},
+		// 10.2.7 takes hotfixes from 2024-04-17, before its release.
+		{"2024-04-17", "10.2.6", "10.3"},
+		{"2024-05-06", "10.2.6", "10.3"},
+		{"2024-05-07", "10.2.7", "10.3"},
+		{"2024-07-23", "11.0.0", "11.0"},
+		{"2030-01-01", "11.0.0", "11.0"},
+	}
+
+	for _, tt := range tests {
+		date, _ := time.Parse(time.DateOnly, tt.date)
+
+		var live, site string
+		if p, ok := calendar.PatchAt(date); ok {
+			live = p.Version
+		}
+		if p, ok := calendar.SitePatchAt(date); ok {
+			site = p.SiteKey()
+		}
+
+		if live != tt.live {
+			t.Errorf("PatchAt(%s) = %q, want %q", tt.date, live, tt.live)
+		}
+		if site != tt.site {
+			t.Errorf("SitePatchAt(%s) = %q, want %q", tt.date, site, tt.site)
+		}
+	}
+}
+
+func TestAssignVersions(t *testing.T) {
+	calendar := readTestCalendar(t)
+
+	tests := []struct {
+		name   string
+		change Change
+		want   string
+	}{
+		{
+			name:   "hotfix",
+			change: Change{Date: "2024-05-08", Tags: []string{"Classes"}},
+			want:   "10.2.7",
+		},
+		{
+			name:   "hotfix with outdated version",
+			change: Change{Date: "2024-05-08", Tags: []string{"Classes"}, Version: "10.2.6"},
+			want:   "10.2.7",
+		},
+		{
+			name:   "content update published before release",
+			change: Change{Date: "2024-04-19", Tags: []string{"10.2.7", "Classes"}},
+			want:   "10.2.7",
+		},
+		{
+			name:   "classic",
+			change: Change{Date: "2024-05-08", Flavor: FlavorClassicEra, Version: "1.15.2"},
+			want:   "1.15.2",
+		},
+		{
+			name:   "before the calendar",
+			change: Change{Date: "2020-01-01", Version: "9.0.1"},
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		changes := []Change{tt.change}
+		calendar.assignVersions(changes)
+		if got := changes[0].Version; got != tt.want {
+			t.Errorf("%s: version %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
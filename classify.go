@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sync/atomic"
+)
+
+// Classifier decides whether the text of a leaf in the Tree is a date, a tag
+// or a change.
+type Classifier interface {
+	Classify(t *Tree, loc *Locale) Classification
+}
+
+// Classification is the decision of a Classifier about a single text.
+type Classification struct {
+	Type TextType
+	// Rule is a short description of the rule that decided Type.
+	Rule string
+	// Confidence is between 0 and 1. Only the BayesClassifier computes it as a
+	// probability; the other classifiers use fixed values per rule.
+	Confidence float64
+}
+
+// PhraseClassifier classifies texts by phrases that are typical for change
+// notes, such as "fixed an issue" or "no longer". Texts that don't match any
+// rule are classified by Fallback.
+type PhraseClassifier struct {
+	Rules    []*PhraseRule
+	Fallback Classifier
+
+	fallbackHits atomic.Int64
+}
+
+// phraseConfidence is the confidence of all decisions by a PhraseRule.
+const phraseConfidence = 0.9
+
+// PhraseRule is a single rule of the PhraseClassifier.
+type PhraseRule struct {
+	Name string
+	// Pattern is a regular expression that must match whole words of the
+	// text, ignoring case.
+	Pattern string
+	Type    TextType
+	// Locales limits the rule to these locales. If empty, the rule applies
+	// to all locales.
+	Locales []string `json:",omitempty"`
+
+	re   *regexp.Regexp
+	hits atomic.Int64
+}
+
+// readPhrases reads the rules of a PhraseClassifier from fname.
+func readPhrases(fname string, fallback Classifier) (*PhraseClassifier, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &PhraseClassifier{Fallback: fallback}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+
+	for _, r := range c.Rules {
+		r.re, err = regexp.Compile(`(?i)\b(?:` + r.Pattern + `)\b`)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", fname, r.Name, err)
+		}
+		if r.Type != TypeTag && r.Type != TypeChange {
+			return nil, fmt.Errorf("%s: %s: type must be %q or %q", fname, r.Name, TypeTag, TypeChange)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *PhraseClassifier) Classify(t *Tree, loc *Locale) Classification {
+	if len(t.Children) > 0 {
+		return Classification{TypeUnclassified, "has children", 1}
+	}
+	if _, err := loc.ParseDate(t.Text); err == nil {
+		return Classification{TypeDate, "parses as date", 1}
+	}
+
+	for _, r := range c.Rules {
+		if r.appliesTo(loc) && r.re.MatchString(t.Text) {
+			r.hits.Add(1)
+			return Classification{r.Type, "phrase: " + r.Name, phraseConfidence}
+		}
+	}
+
+	c.fallbackHits.Add(1)
+	return c.Fallback.Classify(t, loc)
+}
+
+func (r *PhraseRule) appliesTo(loc *Locale) bool {
+	if len(r.Locales) == 0 {
+		return true
+	}
+	return sliceContains(r.Locales, loc.Name)
+}
+
+// WriteStats writes the number of texts each rule has classified so far, so
+// that rules that never match, or match far too often, can be spotted.
+func (c *PhraseClassifier) WriteStats(w io.Writer) {
+	for _, r := range c.Rules {
+		fmt.Fprintf(w, "%6d %-6s %s\n", r.hits.Load(), r.Type, r.Name)
+	}
+	fmt.Fprintf(w, "%6d %-6s %s\n", c.fallbackHits.Load(), "", "(fallback)")
+}
+
+// classifierOptions are the flags selecting and configuring the Classifier.
+type classifierOptions struct {
+	name              string
+	phrasesFile       string
+	modelFile         string
+	overridesFile     string
+	minConfidence     float64
+	lowConfidenceFile string
+
+	phrases   *PhraseClassifier
+	overrides *OverrideClassifier
+}
+
+func (o *classifierOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.name, "classifier", "heuristic",
+		"Classify texts with this classifier; one of 'heuristic', 'phrases'.")
+	fs.StringVar(&o.phrasesFile, "phrases", "phrases.json",
+		"Read the rules of the 'phrases' classifier from this file.")
+	fs.StringVar(&o.modelFile, "model", "",
+		"Classify texts the heuristics are uncertain about with the naive Bayes\n"+
+			"model in this file; see the train command.")
+	fs.StringVar(&o.overridesFile, "overrides", "overrides.json",
+		"Force the type of the texts listed in this file.")
+	fs.Float64Var(&o.minConfidence, "min-confidence", 0.75,
+		"Report classifications less confident than this; see -low-confidence.")
+	fs.StringVar(&o.lowConfidenceFile, "low-confidence", "",
+		"Write a JSON report of all classifications below -min-confidence to this\n"+
+			"file, for review. Wrong ones can be fixed in the -overrides file.")
+}
+
+// classifier creates the Classifier after the flags have been parsed.
+func (o *classifierOptions) classifier() (Classifier, error) {
+	var c Classifier = HeuristicClassifier{}
+	if o.modelFile != "" {
+		m, err := readBayesModel(o.modelFile)
+		if err != nil {
+			return nil, err
+		}
+		c = &BayesClassifier{Model: m, Base: c, MinProbability: bayesMinProbability}
+	}
+
+	switch o.name {
+	case "heuristic":
+	case "phrases":
+		var err error
+		o.phrases, err = readPhrases(o.phrasesFile, c)
+		if err != nil {
+			return nil, err
+		}
+		c = o.phrases
+	default:
+		return nil, fmt.Errorf("unknown classifier %q", o.name)
+	}
+
+	overrides, err := readOverrides(o.overridesFile)
+	if err != nil {
+		return nil, err
+	}
+	o.overrides = newOverrideClassifier(c, overrides, o.minConfidence)
+
+	return o.overrides, nil
+}
+
+// finish logs the rule statistics of the classifier, if it keeps any, and
+// writes the low-confidence report.
+func (o *classifierOptions) finish() error {
+	if o.phrases != nil {
+		log.Println("classifier rule hits:")
+		o.phrases.WriteStats(os.Stderr)
+	}
+
+	if o.overrides == nil {
+		return nil
+	}
+	ds := o.overrides.LowConfidence()
+	if len(ds) > 0 {
+		log.Printf("%d classifications below confidence %.2f", len(ds), o.minConfidence)
+	}
+	if o.lowConfidenceFile == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(struct{ Decisions []Decision }{ds}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(o.lowConfidenceFile, append(b, '\n'))
+}
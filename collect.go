@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/exp/slices"
+)
+
+// Cutoff determines how far back collectPostURLs goes. Articles are listed
+// newest first, so collection stops at the first article that is older than
+// Since, or after the article matching StopAfter, whichever comes first.
+type Cutoff struct {
+	// StopAfter is a part of the URL of the oldest article to collect.
+	StopAfter string
+	// Since and Until limit the publishing dates of collected articles.
+	// Both are inclusive.
+	Since Date
+	Until Date
+	// MaxPages is the maximum number of search result pages to read.
+	MaxPages int
+}
+
+func (c Cutoff) String() string {
+	var conds []string
+	if c.StopAfter != "" {
+		conds = append(conds, fmt.Sprintf("article matching %q", c.StopAfter))
+	}
+	if !c.Since.IsZero() {
+		conds = append(conds, "article published before "+c.Since.String())
+	}
+	return strings.Join(conds, " or ")
+}
+
+// Date is a calendar day in UTC.
+type Date struct {
+	time.Time
+}
+
+func (d Date) String() string {
+	return d.Format(time.DateOnly)
+}
+
+func (d *Date) Set(s string) error {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// collectSearches collects the URLs of the articles listed in the search
+// results at all indexURLs. reached reports whether any search has reached the
+// cutoff; errors are added to report.
+//
+// The article matching StopAfter usually shows up in only one of the
+// searches. Once it has been found, the remaining searches stop at its
+// publishing date, and older articles listed by the previous ones are dropped.
+func collectSearches(ctx context.Context, f *Fetcher, indexURLs []string, cutoff Cutoff, report *Report) (urls []string, reached bool) {
+	dates := map[string]time.Time{}
+	for _, indexURL := range indexURLs {
+		var r bool
+		var err error
+		urls, r, err = collectPostURLs(ctx, f, urls, dates, indexURL, cutoff)
+		if err != nil {
+			report.Add(indexURL, StageCollect, err)
+		}
+		reached = reached || r
+
+		if cutoff.StopAfter == "" {
+			continue
+		}
+		for _, u := range urls {
+			if d, ok := dates[u]; ok && strings.Contains(u, cutoff.StopAfter) && d.After(cutoff.Since.Time) {
+				cutoff.Since = Date{d}
+			}
+		}
+	}
+
+	if !cutoff.Since.IsZero() {
+		urls = slices.DeleteFunc(urls, func(u string) bool {
+			d, ok := dates[u]
+			return ok && d.Before(cutoff.Since.Time)
+		})
+	}
+
+	return urls, reached
+}
+
+// collectPostURLs appends the URLs of all articles listed in the search
+// results at indexURL to urls, following pagination until the cutoff has been
+// reached. reached reports whether it has. The publishing dates of the
+// articles, if listed, are recorded in dates.
+func collectPostURLs(ctx context.Context, f *Fetcher, urls []string, dates map[string]time.Time, indexURL string, cutoff Cutoff) (_ []string, reached bool, _ error) {
+	pageURL := indexURL
+	var prevLinks []string
+
+	for n := 1; ; n++ {
+		page, err := f.Get(ctx, pageURL)
+		if err != nil {
+			return urls, false, err
+		}
+
+		doc, err := page.Document()
+		if err != nil {
+			return urls, false, err
+		}
+
+		var links []string
+		doc.Find(".NewsBlog-link").EachWithBreak(func(i int, s *goquery.Selection) bool {
+			href, _ := s.Attr("href")
+			links = append(links, href)
+
+			u, perr := url.Parse(href)
+			if perr != nil {
+				err = fmt.Errorf("invalid URL in href: %w", perr)
+				return false
+			}
+
+			date, ok := listingDate(s)
+			if ok {
+				if !cutoff.Until.IsZero() && date.After(cutoff.Until.Time) {
+					return true
+				}
+				if !cutoff.Since.IsZero() && date.Before(cutoff.Since.Time) {
+					reached = true
+					return false
+				}
+			} else if !cutoff.Since.IsZero() || !cutoff.Until.IsZero() {
+				log.Println("no publishing date for", href)
+			}
+
+			absURL := doc.Url.ResolveReference(u).String()
+
+			if !sliceContains(urls, absURL) {
+				urls = append(urls, absURL)
+				if ok {
+					dates[absURL] = date
+				}
+			}
+
+			reached = cutoff.StopAfter != "" && strings.Contains(href, cutoff.StopAfter)
+			return !reached
+		})
+
+		if err != nil || reached {
+			return urls, reached, err
+		}
+
+		// Past the last page, or the site ignores the page parameter.
+		if len(links) == 0 || slices.Equal(links, prevLinks) || n >= cutoff.MaxPages {
+			return urls, false, nil
+		}
+		prevLinks = links
+
+		pageURL, err = nextPageURL(doc, n)
+		if err != nil {
+			return urls, false, err
+		}
+	}
+}
+
+// nextPageURL returns the URL of the search results page following page n,
+// which is doc. It follows rel=next links if there are any and otherwise
+// increments the page query parameter.
+func nextPageURL(doc *goquery.Document, n int) (string, error) {
+	if href, ok := doc.Find(`link[rel="next"], a[rel="next"]`).First().Attr("href"); ok {
+		u, err := url.Parse(href)
+		if err != nil {
+			return "", fmt.Errorf("invalid URL in rel=next: %w", err)
+		}
+		return doc.Url.ResolveReference(u).String(), nil
+	}
+
+	u := *doc.Url
+	q := u.Query()
+	q.Set("page", strconv.Itoa(n+1))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+func sliceContains(xs []string, x string) bool {
+	for _, a := range xs {
+		if a == x {
+			return true
+		}
+	}
+	return false
+}
+
+// listingDate returns the publishing date of the article in the search
+// results item s, truncated to the day.
+func listingDate(s *goquery.Selection) (time.Time, bool) {
+	var t time.Time
+
+	// Dates are rendered client side from the ISO 8601 timestamp in the
+	// data-props attribute.
+	s.Find("[data-props]").EachWithBreak(func(i int, p *goquery.Selection) bool {
+		var props struct {
+			ISO8601 string `json:"iso8601"`
+		}
+		attr, _ := p.Attr("data-props")
+		if json.Unmarshal([]byte(attr), &props) != nil || props.ISO8601 == "" {
+			return true
+		}
+
+		var err error
+		t, err = time.Parse(time.RFC3339, props.ISO8601)
+		return err != nil
+	})
+
+	if t.IsZero() {
+		if dt, ok := s.Find("time[datetime]").Attr("datetime"); ok {
+			t, _ = time.Parse(time.RFC3339, dt)
+		}
+	}
+
+	if t.IsZero() {
+		text := strings.TrimSpace(s.Find(".NewsBlog-date").Text())
+		for _, layout := range []string{"January 2, 2006", "Jan 2, 2006", "1/2/2006"} {
+			var err error
+			if t, err = time.Parse(layout, text); err == nil {
+				break
+			}
+		}
+	}
+
+	if t.IsZero() {
+		return t, false
+	}
+
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true
+}
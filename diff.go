@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// ChangeDiff is the difference between two patch notes files.
+type ChangeDiff struct {
+	Added    []Change
+	Removed  []Change
+	Modified []Modification
+}
+
+// Modification is a change note that has been edited between two runs.
+type Modification struct {
+	Old Change
+	New Change
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes diff [flags] OLD.json NEW.json")
+		fs.PrintDefaults()
+	}
+
+	var format string
+	fs.StringVar(&format, "format", "text", "Output format; one of 'text', 'json'.")
+
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	oldNotes, err := readPatchNotes(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	newNotes, err := readPatchNotes(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ensureIDs(oldNotes.Changes)
+	ensureIDs(newNotes.Changes)
+
+	d := diffChanges(oldNotes.Changes, newNotes.Changes)
+
+	switch format {
+	case "json":
+		b, _ := json.MarshalIndent(d, "", "  ")
+		fmt.Println(string(b))
+	case "text":
+		d.WriteText(os.Stdout)
+	default:
+		log.Fatalf("unknown format %q; want one of 'text', 'json'", format)
+	}
+}
+
+// diffChanges compares changes by ID. Notes with the same ID are modified if
+// their text or tags differ, e.g. in case only. Notes that only exist on one
+// side are paired up as modifications if they belong to the same article, date
+// and tags and their texts are similar enough.
+func diffChanges(old, new []Change) *ChangeDiff {
+	oldIDs := map[string]bool{}
+	for _, c := range old {
+		oldIDs[c.ID] = true
+	}
+	newByID := map[string]Change{}
+	for _, c := range new {
+		newByID[c.ID] = c
+	}
+
+	d := &ChangeDiff{}
+
+	var removed, added []Change
+	for _, c := range old {
+		n, ok := newByID[c.ID]
+		switch {
+		case !ok:
+			removed = append(removed, c)
+		case n.Text != c.Text || !slices.Equal(n.Tags, c.Tags):
+			d.Modified = append(d.Modified, Modification{Old: c, New: n})
+		}
+	}
+	for _, c := range new {
+		if !oldIDs[c.ID] {
+			added = append(added, c)
+		}
+	}
+
+	paired := make([]bool, len(added))
+	for _, o := range removed {
+		match := -1
+		for i, n := range added {
+			if paired[i] || !sameContext(o, n) {
+				continue
+			}
+			if similarity(o.Text, n.Text) >= 0.5 {
+				match = i
+				break
+			}
+		}
+
+		if match < 0 {
+			d.Removed = append(d.Removed, o)
+			continue
+		}
+
+		paired[match] = true
+		d.Modified = append(d.Modified, Modification{Old: o, New: added[match]})
+	}
+
+	for i, n := range added {
+		if !paired[i] {
+			d.Added = append(d.Added, n)
+		}
+	}
+
+	return d
+}
+
+func sameContext(a, b Change) bool {
+	return a.URL == b.URL && a.Date == b.Date &&
+		strings.Join(a.Tags, "\x00") == strings.Join(b.Tags, "\x00")
+}
+
+// similarity returns the Jaccard index of the words in a and b.
+func similarity(a, b string) float64 {
+	as := map[string]bool{}
+	for _, w := range strings.Fields(normalizeText(a)) {
+		as[w] = true
+	}
+	bs := map[string]bool{}
+	for _, w := range strings.Fields(normalizeText(b)) {
+		bs[w] = true
+	}
+
+	var common int
+	for w := range as {
+		if bs[w] {
+			common++
+		}
+	}
+
+	union := len(as) + len(bs) - common
+	if union == 0 {
+		return 1
+	}
+	return float64(common) / float64(union)
+}
+
+func (d *ChangeDiff) WriteText(w io.Writer) {
+	header := func(c Change) string {
+		return fmt.Sprintf("%s [%s] %s", c.Date, strings.Join(c.Tags, " > "), c.URL)
+	}
+
+	for _, c := range d.Added {
+		fmt.Fprintf(w, "+ %s\n    %s\n", header(c), c.Text)
+	}
+	for _, c := range d.Removed {
+		fmt.Fprintf(w, "- %s\n    %s\n", header(c), c.Text)
+	}
+	for _, m := range d.Modified {
+		fmt.Fprintf(w, "~ %s\n  - %s\n  + %s\n", header(m.New), m.Old.Text, m.New.Text)
+		if !slices.Equal(m.Old.Tags, m.New.Tags) {
+			fmt.Fprintf(w, "    tags were [%s]\n", strings.Join(m.Old.Tags, " > "))
+		}
+	}
+
+	fmt.Fprintf(w, "%d added, %d removed, %d modified\n",
+		len(d.Added), len(d.Removed), len(d.Modified))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func runTree(args []string) {
+	fs := flag.NewFlagSet("tree", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes tree [flags] FILE|URL")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Print the classified tree of an article, showing why each line is")
+		fmt.Fprintln(fs.Output(), "considered a date, tag or change.")
+		fs.PrintDefaults()
+	}
+
+	var format string
+	var firstHeader string
+	var localeName string
+	var articlesFile string
+	fetcher := &Fetcher{Mode: CacheOnline, Timeout: 30 * time.Second, Retries: 3}
+
+	fs.StringVar(&format, "format", "text", "Output format; one of 'text', 'json'.")
+	fs.StringVar(&firstHeader, "first-header", "",
+		"Skip everything before the element matching this selector, as for content\n"+
+			"updates. Defaults to the rule for URL in the article registry.")
+	fs.StringVar(&localeName, "locale", "",
+		"Classify dates of this locale. Defaults to the locale of URL, or en-us for files.")
+	fs.StringVar(&articlesFile, "articles", "articles.json",
+		"Read the article registry from this file.")
+	fs.StringVar(&fetcher.CacheDir, "cache-dir", "",
+		"Cache fetched pages in this directory.")
+	fs.Var(&fetcher.Mode, "cache",
+		"Cache mode; one of 'online', 'cache-first', 'offline'.")
+	var classifierOpts classifierOptions
+	classifierOpts.register(fs)
+
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	src := fs.Arg(0)
+
+	classifier, err := classifierOpts.classifier()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var doc *goquery.Document
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		doc, err = fetchDocument(context.Background(), fetcher, src)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if firstHeader == "" {
+			registry, err := readRegistry(articlesFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if a, ok := registry.Lookup(doc.Url); ok && a.Kind == KindContentUpdate {
+				firstHeader = a.FirstHeader
+			}
+		}
+	} else {
+		f, err := os.Open(src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		doc, err = goquery.NewDocumentFromReader(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	loc := localeOf(doc.Url)
+	if localeName != "" {
+		loc, err = lookupLocale(localeName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	root, err := articleRoot(doc, firstHeader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if doc.Url != nil {
+		classifier = classifierFor(classifier, articleURL(doc.Url))
+	}
+	tree := buildTree(root, doc.Url, loc, classifier)
+	if err := classifierOpts.finish(); err != nil {
+		log.Println(err)
+	}
+
+	switch format {
+	case "json":
+		b, _ := json.MarshalIndent(tree, "", "  ")
+		fmt.Println(string(b))
+	case "text":
+		writeTree(os.Stdout, tree, 0)
+	default:
+		log.Fatalf("unknown format %q", format)
+	}
+}
+
+// writeTree writes t as an indented outline. Every node is annotated with its
+// type, the confidence of the classifier and the rule that decided it, e.g.
+//
+//	[unclassified 1.00: has children]
+//	  [tag 0.60: default] Mage
+//	  [change 0.90: contains "."] Fixed an issue with Arcane Missiles.
+func writeTree(w io.Writer, t *Tree, depth int) {
+	fmt.Fprintf(w, "%s[%s %.2f: %s]", strings.Repeat("  ", depth), t.Type, t.Confidence, t.Rule)
+	if t.Text != "" {
+		fmt.Fprintf(w, " %s", t.Text)
+	}
+	fmt.Fprintln(w)
+
+	for _, c := range t.Children {
+		writeTree(w, c, depth+1)
+	}
+}
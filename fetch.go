@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type CacheMode string
+
+const (
+	// CacheOnline always fetches pages from the network and stores them in
+	// the cache.
+	CacheOnline CacheMode = "online"
+	// CacheFirst uses cached pages if available and fetches all others.
+	CacheFirst CacheMode = "cache-first"
+	// CacheOffline never touches the network; uncached pages are an error.
+	CacheOffline CacheMode = "offline"
+)
+
+func (m *CacheMode) String() string { return string(*m) }
+
+func (m *CacheMode) Set(s string) error {
+	switch CacheMode(s) {
+	case CacheOnline, CacheFirst, CacheOffline:
+		*m = CacheMode(s)
+		return nil
+	default:
+		return fmt.Errorf("want one of %q, %q, %q", CacheOnline, CacheFirst, CacheOffline)
+	}
+}
+
+// Fetcher fetches web pages, optionally backed by an on-disk cache.
+//
+// Cached pages are revalidated with conditional requests. Requests that fail
+// with 429 or 5xx are retried with exponential backoff, and requests to the
+// same host are spaced at least Interval apart.
+type Fetcher struct {
+	Client *http.Client
+
+	// CacheDir is the directory cached pages are stored in. If empty,
+	// nothing is cached and Mode must be CacheOnline.
+	CacheDir string
+	Mode     CacheMode
+
+	// Timeout limits the duration of a single request, including reading
+	// the response body.
+	Timeout time.Duration
+	// Retries is the number of times a failed request is retried.
+	Retries int
+	// Interval is the minimum time between two requests to the same host.
+	Interval time.Duration
+	// Robots makes the fetcher honor robots.txt.
+	Robots bool
+
+	mu     sync.Mutex
+	next   map[string]time.Time    // host -> earliest time of next request
+	robots map[string]*robotsRules // host -> rules
+}
+
+// Page is a fetched web page. It is stored as JSON in the cache.
+type Page struct {
+	URL          string // as requested
+	FinalURL     string // after redirects
+	Status       int
+	Header       http.Header
+	Body         []byte
+	FetchedAt    time.Time
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+}
+
+// Document parses the page body as HTML.
+func (p *Page) Document() (*goquery.Document, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(p.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Url, err = url.Parse(p.FinalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func (f *Fetcher) Get(ctx context.Context, u string) (*Page, error) {
+	cached, err := f.readCache(u)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println("cache:", err)
+	}
+
+	if cached != nil && (f.Mode == CacheFirst || f.Mode == CacheOffline) {
+		return cached, nil
+	}
+	if f.Mode == CacheOffline {
+		return nil, fmt.Errorf("%s: not in cache", u)
+	}
+
+	if f.Robots {
+		ok, err := f.allowed(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s: disallowed by robots.txt", u)
+		}
+	}
+
+	p, err := f.fetchWithRetry(ctx, u, cached)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.writeCache(p); err != nil {
+		log.Println("cache:", err)
+	}
+
+	return p, nil
+}
+
+type statusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URL, e.Status)
+}
+
+func (e *statusError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func (f *Fetcher) fetchWithRetry(ctx context.Context, u string, cached *Page) (*Page, error) {
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		p, err := f.fetch(ctx, u, cached)
+		if err == nil || attempt >= f.Retries || ctx.Err() != nil {
+			return p, err
+		}
+
+		wait := backoff
+		backoff *= 2
+
+		var se *statusError
+		if errors.As(err, &se) {
+			if !se.temporary() {
+				return nil, err
+			}
+			if se.RetryAfter > 0 {
+				wait = se.RetryAfter
+			}
+		}
+
+		log.Printf("%v; retrying in %v", err, wait)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fetch sends a single request for u. If cached is not nil, the request is
+// conditional and cached is returned if the page has not been modified.
+func (f *Fetcher) fetch(ctx context.Context, u string, cached *Page) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	if err := f.wait(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		io.Copy(io.Discard, res.Body)
+
+		p := *cached
+		p.FetchedAt = time.Now().UTC()
+		return &p, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, res.Body)
+		return nil, &statusError{
+			URL:        u,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page{
+		URL:          u,
+		FinalURL:     res.Request.URL.String(),
+		Status:       res.StatusCode,
+		Header:       res.Header,
+		Body:         body,
+		FetchedAt:    time.Now().UTC(),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// wait blocks until the next request to host may be sent.
+func (f *Fetcher) wait(ctx context.Context, host string) error {
+	if f.Interval <= 0 {
+		return nil
+	}
+
+	f.mu.Lock()
+	if f.next == nil {
+		f.next = map[string]time.Time{}
+	}
+	now := time.Now()
+	t := f.next[host]
+	if t.Before(now) {
+		t = now
+	}
+	f.next[host] = t.Add(f.Interval)
+	f.mu.Unlock()
+
+	return sleep(ctx, time.Until(t))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (f *Fetcher) cacheFile(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return filepath.Join(f.CacheDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (f *Fetcher) readCache(u string) (*Page, error) {
+	if f.CacheDir == "" {
+		return nil, fs.ErrNotExist
+	}
+
+	b, err := os.ReadFile(f.cacheFile(u))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Page{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.cacheFile(u), err)
+	}
+
+	return p, nil
+}
+
+func (f *Fetcher) writeCache(p *Page) error {
+	if f.CacheDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(f.CacheDir, 0o755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.cacheFile(p.URL), b, 0o644)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer serves the responses in order and records the requests.
+type testServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []func(http.ResponseWriter, *http.Request)
+	requests  []*http.Request
+	times     []time.Time
+}
+
+func newTestServer(t *testing.T, responses ...func(http.ResponseWriter, *http.Request)) *testServer {
+	s := &testServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		n := len(s.requests)
+		s.requests = append(s.requests, r)
+		s.times = append(s.times, time.Now())
+		s.mu.Unlock()
+
+		if n >= len(s.responses) {
+			t.Errorf("unexpected request %d for %s", n+1, r.URL)
+			http.Error(w, "unexpected", http.StatusInternalServerError)
+			return
+		}
+		s.responses[n](w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func respond(status int, header map[string]string, body string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestFetchNotModified(t *testing.T) {
+	s := newTestServer(t,
+		respond(http.StatusOK, map[string]string{"ETag": `"v1"`, "Last-Modified": "Wed, 03 Jul 2024 10:00:00 GMT"}, "page"),
+		respond(http.StatusNotModified, nil, ""),
+	)
+	f := &Fetcher{CacheDir: t.TempDir(), Mode: CacheOnline}
+
+	p, err := f.Get(context.Background(), s.URL+"/news/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := p.FetchedAt
+
+	p, err = f.Get(context.Background(), s.URL+"/news/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.requests[1].Header.Get("If-None-Match"); got != `"v1"` {
+		t.Errorf("If-None-Match %q, want %q", got, `"v1"`)
+	}
+	if got := s.requests[1].Header.Get("If-Modified-Since"); got != "Wed, 03 Jul 2024 10:00:00 GMT" {
+		t.Errorf("If-Modified-Since %q", got)
+	}
+	if string(p.Body) != "page" || p.Status != http.StatusOK {
+		t.Errorf("got %d %q, want the cached page", p.Status, p.Body)
+	}
+	if !p.FetchedAt.After(first) {
+		t.Errorf("FetchedAt %v not updated", p.FetchedAt)
+	}
+}
+
+func TestFetchRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []func(http.ResponseWriter, *http.Request)
+		retries   int
+		minWait   time.Duration
+		wantErr   bool
+	}{
+		{
+			name: "429 with Retry-After",
+			responses: []func(http.ResponseWriter, *http.Request){
+				respond(http.StatusTooManyRequests, map[string]string{"Retry-After": "1"}, ""),
+				respond(http.StatusOK, nil, "page"),
+			},
+			retries: 1,
+			minWait: time.Second,
+		},
+		{
+			name: "5xx then success",
+			responses: []func(http.ResponseWriter, *http.Request){
+				respond(http.StatusServiceUnavailable, nil, ""),
+				respond(http.StatusOK, nil, "page"),
+			},
+			retries: 1,
+		},
+		{
+			name: "5xx without retries",
+			responses: []func(http.ResponseWriter, *http.Request){
+				respond(http.StatusBadGateway, nil, ""),
+			},
+			wantErr: true,
+		},
+		{
+			name: "404 is not retried",
+			responses: []func(http.ResponseWriter, *http.Request){
+				respond(http.StatusNotFound, nil, ""),
+			},
+			retries: 3,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.responses...)
+			f := &Fetcher{Mode: CacheOnline, Retries: tt.retries}
+
+			p, err := f.Get(context.Background(), s.URL+"/news/1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && string(p.Body) != "page" {
+				t.Errorf("body %q, want %q", p.Body, "page")
+			}
+			if len(s.requests) != len(tt.responses) {
+				t.Errorf("%d requests, want %d", len(s.requests), len(tt.responses))
+			}
+			if n := len(s.times); n > 1 {
+				if wait := s.times[n-1].Sub(s.times[0]); wait < tt.minWait {
+					t.Errorf("retried after %v, want at least %v", wait, tt.minWait)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("parseRetryAfter(120) = %v", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%s) = %v", date, got)
+	}
+	for _, s := range []string{"", "-1", "soon"} {
+		if got := parseRetryAfter(s); got != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", s, got)
+		}
+	}
+}
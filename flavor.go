@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Game flavors that patch notes apply to.
+const (
+	FlavorRetail            = "Retail"
+	FlavorWrathClassic      = "Wrath Classic"
+	FlavorCataclysmClassic  = "Cataclysm Classic"
+	FlavorClassicEra        = "Classic Era"
+	FlavorHardcore          = "Hardcore"
+	FlavorSeasonOfDiscovery = "Season of Discovery"
+	FlavorPlunderstorm      = "Plunderstorm"
+)
+
+var flavors = []string{
+	FlavorRetail,
+	FlavorWrathClassic,
+	FlavorCataclysmClassic,
+	FlavorClassicEra,
+	FlavorHardcore,
+	FlavorSeasonOfDiscovery,
+	FlavorPlunderstorm,
+}
+
+// flavorTag maps a substring of tags to the flavor it indicates.
+type flavorTag struct {
+	substr string
+	flavor string
+}
+
+// flavorTags are the English flavor names. They are used in every locale,
+// because many articles don't translate them.
+var flavorTags = []flavorTag{
+	{"WotLK", FlavorWrathClassic},
+	{"Wrath Classic", FlavorWrathClassic},
+	{"Wrath of the Lich King Classic", FlavorWrathClassic},
+	{"WoW Classic Hardcore", FlavorHardcore},
+	{"Classic Era", FlavorClassicEra},
+	{"Cataclysm Classic", FlavorCataclysmClassic},
+	{"Plunderstorm", FlavorPlunderstorm},
+	{"Season of Discovery", FlavorSeasonOfDiscovery},
+}
+
+// flavorOf returns the flavor indicated by tags, which may use the English
+// or the localized flavor names in any case. Tags are ordered from the most
+// general to the most specific, so the last matching tag wins.
+func (l *Locale) flavorOf(tags []string) string {
+	flavor := FlavorRetail
+	for _, t := range tags {
+		if f := l.tagFlavor(t); f != "" {
+			flavor = f
+		}
+	}
+	return flavor
+}
+
+func (l *Locale) tagFlavor(t string) string {
+	t = strings.ToUpper(t)
+	for _, fts := range [][]flavorTag{flavorTags, l.flavors} {
+		for _, ft := range fts {
+			if strings.Contains(t, strings.ToUpper(ft.substr)) {
+				return ft.flavor
+			}
+		}
+	}
+	return ""
+}
+
+// flavorSlug returns the flavor name as used in file names and flags, e.g.
+// "cataclysm-classic".
+func flavorSlug(flavor string) string {
+	return strings.ReplaceAll(strings.ToLower(flavor), " ", "-")
+}
+
+// FlavorFilter selects changes by flavor. It is a flag.Value accepting a
+// comma-separated list of flavors, either by name or slug, or "all".
+type FlavorFilter map[string]bool
+
+func (f FlavorFilter) String() string {
+	var names []string
+	for _, fl := range flavors {
+		if f[fl] {
+			names = append(names, flavorSlug(fl))
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (f FlavorFilter) Set(s string) error {
+	for k := range f {
+		delete(f, k)
+	}
+
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			for _, fl := range flavors {
+				f[fl] = true
+			}
+			continue
+		}
+
+		var ok bool
+		for _, fl := range flavors {
+			if strings.EqualFold(name, fl) || name == flavorSlug(fl) {
+				f[fl] = true
+				ok = true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("unknown flavor %q", name)
+		}
+	}
+
+	return nil
+}
+
+// Filter returns the changes matching f. Changes without a flavor predate
+// flavors and are considered to be retail changes.
+func (f FlavorFilter) Filter(changes []Change) []Change {
+	filtered := make([]Change, 0, len(changes))
+	for _, c := range changes {
+		flavor := c.Flavor
+		if flavor == "" {
+			flavor = FlavorRetail
+		}
+		if f[flavor] {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// changeID returns a stable identifier for a change note. It is derived from
+// the article, the date and the normalized text of the note, so it survives
+// re-scraping, re-ordering and changes in capitalization or whitespace. n
+// disambiguates identical notes in the same article and on the same date.
+func changeID(srcURL string, date time.Time, text string, n int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", srcURL, date.Format(time.DateOnly), normalizeText(text))
+	if n > 0 {
+		fmt.Fprintf(h, "\x00%d", n)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func normalizeText(s string) string {
+	s = strings.ToLower(s)
+	s = strings.NewReplacer("’", "'", "‘", "'", "“", `"`, "”", `"`).Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// nextID returns the ID for the next note with the given text, using ids to
+// keep track of notes that have been seen before.
+func nextID(ids map[string]int, srcURL string, date time.Time, text string) string {
+	base := changeID(srcURL, date, text, 0)
+	n := ids[base]
+	ids[base]++
+
+	if n == 0 {
+		return base
+	}
+	return changeID(srcURL, date, text, n)
+}
+
+// ensureIDs assigns IDs to changes that have been written before IDs have been
+// introduced.
+func ensureIDs(changes []Change) {
+	ids := map[string]int{}
+	for i, c := range changes {
+		if c.ID != "" {
+			continue
+		}
+
+		date, err := time.Parse(time.DateOnly, c.Date)
+		if err != nil {
+			continue
+		}
+		changes[i].ID = nextID(ids, c.URL, date, c.Text)
+	}
+}
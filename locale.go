@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Locale describes the localized variant of the Blizzard website.
+type Locale struct {
+	Name string // as used in URLs, e.g. "de-de"
+
+	// SearchTerms are the blog search queries that list patch notes and
+	// hotfix articles.
+	SearchTerms []string
+
+	// dateRE matches dates in the articles, with the named groups "day",
+	// "month" and "year". If months is nil, the month is numeric.
+	dateRE *regexp.Regexp
+	months map[string]time.Month
+
+	// tags maps localized tags to their canonical English spelling. Keys are
+	// upper case.
+	tags map[string]string
+
+	// flavors are the localized flavor names, in addition to flavorTags.
+	flavors []flavorTag
+}
+
+const defaultLocale = "en-us"
+
+var locales = map[string]*Locale{}
+
+func init() {
+	en := monthNames("january", "february", "march", "april", "may", "june",
+		"july", "august", "september", "october", "november", "december")
+	de := monthNames("januar", "februar", "märz", "april", "mai", "juni",
+		"juli", "august", "september", "oktober", "november", "dezember")
+	fr := monthNames("janvier", "février", "mars", "avril", "mai", "juin",
+		"juillet", "août", "septembre", "octobre", "novembre", "décembre")
+	es := monthNames("enero", "febrero", "marzo", "abril", "mayo", "junio",
+		"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre")
+	it := monthNames("gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno",
+		"luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre")
+	pt := monthNames("janeiro", "fevereiro", "março", "abril", "maio", "junho",
+		"julho", "agosto", "setembro", "outubro", "novembro", "dezembro")
+	ru := monthNames("января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря")
+
+	esTags := map[string]string{
+		"Clases":                 "Classes",
+		"Mazmorras y bandas":     "Dungeons and Raids",
+		"Calabozos y bandas":     "Dungeons and Raids",
+		"JcJ":                    "PvP",
+		"Jugador contra Jugador": "PvP",
+		"Objetos":                "Items",
+		"Misiones":               "Quests",
+		"Profesiones":            "Professions",
+		"Logros":                 "Achievements",
+		"Interfaz de usuario":    "User Interface",
+		"Criaturas y PNJ":        "Creatures and NPCs",
+		"Mítica+":                "Mythic+",
+		"Reputación":             "Reputation",
+		"Duelos de mascotas":     "Pet Battles",
+		"Eventos":                "Events",
+		"Caballero de la Muerte": "Death Knight",
+		"Cazador de demonios":    "Demon Hunter",
+		"Druida":                 "Druid",
+		"Evocador":               "Evoker",
+		"Cazador":                "Hunter",
+		"Mago":                   "Mage",
+		"Monje":                  "Monk",
+		"Paladín":                "Paladin",
+		"Sacerdote":              "Priest",
+		"Pícaro":                 "Rogue",
+		"Chamán":                 "Shaman",
+		"Brujo":                  "Warlock",
+		"Guerrero":               "Warrior",
+	}
+
+	for _, loc := range []*Locale{
+		{
+			Name:        "en-us",
+			SearchTerms: []string{"Patch Notes", "Update Notes"},
+			dateRE:      regexp.MustCompile(`^(?P<month>\pL+) (?P<day>\d{1,2}), (?P<year>\d{4})$`),
+			months:      en,
+		},
+		{
+			Name:        "en-gb",
+			SearchTerms: []string{"Patch Notes", "Update Notes"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2}) (?P<month>\pL+),? (?P<year>\d{4})$`),
+			months:      en,
+		},
+		{
+			Name:        "de-de",
+			SearchTerms: []string{"Patchnotes", "Hotfixes"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2})\. (?P<month>\pL+) (?P<year>\d{4})$`),
+			months:      de,
+			tags: map[string]string{
+				"Klassen":                   "Classes",
+				"Dungeons und Schlachtzüge": "Dungeons and Raids",
+				"Spieler gegen Spieler":     "PvP",
+				"Gegenstände":               "Items",
+				"Quests":                    "Quests",
+				"Berufe":                    "Professions",
+				"Erfolge":                   "Achievements",
+				"Benutzeroberfläche":        "User Interface",
+				"Kreaturen und NPCs":        "Creatures and NPCs",
+				"Mythisch+":                 "Mythic+",
+				"Ruf":                       "Reputation",
+				"Haustierkämpfe":            "Pet Battles",
+				"Fehlerbehebungen":          "Bug Fixes",
+				"Todesritter":               "Death Knight",
+				"Dämonenjäger":              "Demon Hunter",
+				"Druide":                    "Druid",
+				"Rufer":                     "Evoker",
+				"Jäger":                     "Hunter",
+				"Magier":                    "Mage",
+				"Mönch":                     "Monk",
+				"Paladin":                   "Paladin",
+				"Priester":                  "Priest",
+				"Schurke":                   "Rogue",
+				"Schamane":                  "Shaman",
+				"Hexenmeister":              "Warlock",
+				"Krieger":                   "Warrior",
+			},
+			flavors: []flavorTag{
+				{"Classic-Ära", FlavorClassicEra},
+				{"Saison der Entdeckungen", FlavorSeasonOfDiscovery},
+				{"Plündersturm", FlavorPlunderstorm},
+			},
+		},
+		{
+			Name:        "fr-fr",
+			SearchTerms: []string{"Notes de mise à jour", "Correctifs"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2})(?:er)? (?P<month>\pL+) (?P<year>\d{4})$`),
+			months:      fr,
+			tags: map[string]string{
+				"Classes":               "Classes",
+				"Donjons et raids":      "Dungeons and Raids",
+				"JcJ":                   "PvP",
+				"Joueur contre joueur":  "PvP",
+				"Objets":                "Items",
+				"Quêtes":                "Quests",
+				"Métiers":               "Professions",
+				"Hauts faits":           "Achievements",
+				"Interface utilisateur": "User Interface",
+				"Créatures et PNJ":      "Creatures and NPCs",
+				"Mythique+":             "Mythic+",
+				"Réputation":            "Reputation",
+				"Combats de mascottes":  "Pet Battles",
+				"Évènements":            "Events",
+				"Corrections de bugs":   "Bug Fixes",
+				"Chevalier de la mort":  "Death Knight",
+				"Chasseur de démons":    "Demon Hunter",
+				"Druide":                "Druid",
+				"Évocateur":             "Evoker",
+				"Chasseur":              "Hunter",
+				"Mage":                  "Mage",
+				"Moine":                 "Monk",
+				"Paladin":               "Paladin",
+				"Prêtre":                "Priest",
+				"Voleur":                "Rogue",
+				"Chaman":                "Shaman",
+				"Démoniste":             "Warlock",
+				"Guerrier":              "Warrior",
+			},
+		},
+		{
+			Name:        "es-es",
+			SearchTerms: []string{"Notas del parche", "Correcciones"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2}) de (?P<month>\pL+) de (?P<year>\d{4})$`),
+			months:      es,
+			tags:        esTags,
+		},
+		{
+			Name:        "es-mx",
+			SearchTerms: []string{"Notas del parche", "Correcciones"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2}) de (?P<month>\pL+) de (?P<year>\d{4})$`),
+			months:      es,
+			tags:        esTags,
+		},
+		{
+			Name:        "it-it",
+			SearchTerms: []string{"Note della patch", "Correzioni"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2}) (?P<month>\pL+) (?P<year>\d{4})$`),
+			months:      it,
+		},
+		{
+			Name:        "pt-br",
+			SearchTerms: []string{"Notas do patch", "Correções"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2}) de (?P<month>\pL+) de (?P<year>\d{4})$`),
+			months:      pt,
+		},
+		{
+			Name:        "ru-ru",
+			SearchTerms: []string{"Список изменений", "Исправления"},
+			dateRE:      regexp.MustCompile(`^(?P<day>\d{1,2}) (?P<month>\pL+) (?P<year>\d{4})(?: г\.)?$`),
+			months:      ru,
+		},
+		{
+			Name:        "ko-kr",
+			SearchTerms: []string{"패치 노트", "핫픽스"},
+			dateRE:      regexp.MustCompile(`^(?P<year>\d{4})년 (?P<month>\d{1,2})월 (?P<day>\d{1,2})일$`),
+			tags: map[string]string{
+				"직업":        "Classes",
+				"던전 및 공격대":  "Dungeons and Raids",
+				"플레이어 간 전투": "PvP",
+				"아이템":       "Items",
+				"퀘스트":       "Quests",
+				"전문 기술":     "Professions",
+				"업적":        "Achievements",
+				"사용자 인터페이스": "User Interface",
+				"신화+":       "Mythic+",
+				"평판":        "Reputation",
+				"애완동물 대전":   "Pet Battles",
+				"이벤트":       "Events",
+				"죽음의 기사":    "Death Knight",
+				"악마사냥꾼":     "Demon Hunter",
+				"드루이드":      "Druid",
+				"기원사":       "Evoker",
+				"사냥꾼":       "Hunter",
+				"마법사":       "Mage",
+				"수도사":       "Monk",
+				"성기사":       "Paladin",
+				"사제":        "Priest",
+				"도적":        "Rogue",
+				"주술사":       "Shaman",
+				"흑마법사":      "Warlock",
+				"전사":        "Warrior",
+			},
+		},
+		{
+			Name:        "zh-tw",
+			SearchTerms: []string{"更新內容", "熱修正"},
+			dateRE:      regexp.MustCompile(`^(?P<year>\d{4})年(?P<month>\d{1,2})月(?P<day>\d{1,2})日$`),
+		},
+	} {
+		if loc.tags != nil {
+			tags := make(map[string]string, len(loc.tags))
+			for k, v := range loc.tags {
+				tags[strings.ToUpper(k)] = v
+			}
+			loc.tags = tags
+		}
+		locales[loc.Name] = loc
+	}
+}
+
+func monthNames(names ...string) map[string]time.Month {
+	m := make(map[string]time.Month, len(names))
+	for i, name := range names {
+		m[name] = time.Month(i + 1)
+	}
+	return m
+}
+
+func lookupLocale(name string) (*Locale, error) {
+	loc, ok := locales[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(locales))
+		for n := range locales {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unsupported locale %q; want one of %s", name, strings.Join(names, ", "))
+	}
+	return loc, nil
+}
+
+// localeOf returns the locale of the article at u, falling back to the
+// default locale.
+func localeOf(u *url.URL) *Locale {
+	if u != nil {
+		first, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		if loc, ok := locales[strings.ToLower(first)]; ok {
+			return loc
+		}
+	}
+	return locales[defaultLocale]
+}
+
+// SearchURLs returns the URLs of the blog searches listing all relevant
+// articles.
+func (l *Locale) SearchURLs() []string {
+	var urls []string
+	for _, term := range l.SearchTerms {
+		urls = append(urls, "https://worldofwarcraft.blizzard.com/"+l.Name+
+			"/search/blog?k="+url.PathEscape(term))
+	}
+	return urls
+}
+
+// ParseDate parses the dates used as headers in hotfix articles.
+func (l *Locale) ParseDate(s string) (time.Time, error) {
+	m := l.dateRE.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("%s: not a date: %q", l.Name, s)
+	}
+
+	var year, day int
+	var month time.Month
+	for i, name := range l.dateRE.SubexpNames() {
+		switch name {
+		case "year":
+			year, _ = strconv.Atoi(m[i])
+		case "day":
+			day, _ = strconv.Atoi(m[i])
+		case "month":
+			if l.months == nil {
+				n, _ := strconv.Atoi(m[i])
+				month = time.Month(n)
+			} else {
+				month = l.months[strings.ToLower(m[i])]
+			}
+		}
+	}
+
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if month < time.January || month > time.December || t.Day() != day {
+		return time.Time{}, fmt.Errorf("%s: not a date: %q", l.Name, s)
+	}
+
+	return t, nil
+}
+
+// canonicalTags replaces localized tags with their English spelling where
+// known. Upper case tags that remain are converted to title case, because
+// fixCasing only knows the English ones.
+func (l *Locale) canonicalTags(changes []Change) {
+	if l.Name == defaultLocale {
+		return
+	}
+
+	for i := range changes {
+		for j, t := range changes[i].Tags {
+			if en, ok := l.tags[strings.ToUpper(t)]; ok {
+				changes[i].Tags[j] = en
+			} else if strings.IndexFunc(t, unicode.IsLetter) >= 0 && t == strings.ToUpper(t) {
+				changes[i].Tags[j] = titleCase(t)
+			}
+		}
+	}
+}
+
+func titleCase(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
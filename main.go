@@ -3,11 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -16,227 +15,517 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/exp/slices"
 	"golang.org/x/net/html"
 )
 
 type Change struct {
+	ID      string
 	URL     string
 	Date    string
 	Weekday string
 	Tags    []string
 	Text    string
+
+	// Flavor is the game flavor this change applies to; one of the Flavor*
+	// constants.
+	Flavor string `json:",omitempty"`
+
+	// Version is the patch version this change belongs to: the version of
+	// the content update, or the retail patch that was live when a hotfix
+	// was published.
+	Version string `json:",omitempty"`
+
+	// Translations maps locales to the text of this change in that locale.
+	// See alignLocales.
+	Translations map[string]string `json:",omitempty"`
+
+	// HTML is Text with its inline formatting and links, if it has any, and
+	// Links lists the links in it. Both are only written with -rich.
+	HTML  string `json:",omitempty"`
+	Links []Link `json:",omitempty"`
+
+	// ParentID is the ID of the change this one is a sub-point of, and
+	// Children are the IDs of its own sub-points, in order. Both are only
+	// written with -nested.
+	ParentID string   `json:",omitempty"`
+	Children []string `json:",omitempty"`
 }
 
 const userAgent = "wow-patch-notes/1.0 (+https://wow-patch-notes.github.io)"
 
-func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-
-	var stopAfter string
-
-	flag.StringVar(&stopAfter, "stop-after", "",
-		"Stop parsing after the article who's URL contains this string.")
-
-	flag.Parse()
-
-	if args := flag.Args(); len(args) > 0 {
-		changes := debug(args)
+// commands lists all subcommands in the order they are shown in the usage.
+var commands = []struct {
+	name    string
+	summary string
+	run     func(args []string)
+}{
+	{"scrape", "Scrape patch notes from the blog.", runScrape},
+	{"parse-file", "Scrape a single article saved as HTML.", runParseFile},
+	{"tree", "Print the classified tree of an article.", runTree},
+	{"diff", "Compare two patch notes files.", runDiff},
+	{"validate", "Check that a patch notes file didn't lose changes.", runValidate},
+	{"align", "Record translations across locales.", runAlign},
+	{"tags", "List the tags used in patch notes files.", runTags},
+	{"train", "Train the naive Bayes classifier.", runTrain},
+}
 
-		fixCasing(changes)
-		checkTags(changes)
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "Usage: wow-patch-notes COMMAND [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'wow-patch-notes COMMAND -h' for the flags of each command.")
+}
 
-		sort.SliceStable(changes, func(i, j int) bool {
-			return changes[i].Date > changes[j].Date
-		})
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-		b, _ := json.MarshalIndent(changes, "", "  ")
-		fmt.Println(string(b))
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name, args := os.Args[1], os.Args[2:]
 
-		return
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) == 0 {
+			usage()
+			return
+		}
+		name, args = args[0], []string{"-h"}
 	}
 
-	if stopAfter == "" {
-		log.Fatal("-stop-after is required")
+	// Flags without a command used to mean scrape; keep existing scripts
+	// working for now.
+	if strings.HasPrefix(name, "-") {
+		log.Println("WARN: running without a command is deprecated; use 'wow-patch-notes scrape'")
+		name, args = "scrape", os.Args[1:]
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
+	for _, c := range commands {
+		if c.name == name {
+			c.run(args)
+			return
+		}
+	}
 
-	var urls []string
+	fmt.Fprintf(flag.CommandLine.Output(), "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
-	urls = collectPostURLs(ctx, urls, "https://worldofwarcraft.blizzard.com/en-us/search/blog?k=Patch%20Notes", stopAfter)
-	urls = collectPostURLs(ctx, urls, "https://worldofwarcraft.blizzard.com/en-us/search/blog?k=Update%20Notes", stopAfter)
+func runScrape(args []string) {
+	fs := flag.NewFlagSet("scrape", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes scrape [flags]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Scrape hotfixes and content update notes from the blog. One of -stop-after,")
+		fmt.Fprintln(fs.Output(), "-since or -since-patch is required.")
+		fs.PrintDefaults()
+	}
 
-	allChanges := make([]Change, 0, 5000)
+	var cutoff Cutoff
+	var sincePatch string
+	var localeName string
+	flavorFilter := FlavorFilter{FlavorRetail: true}
+	var mergeFile string
+	var siteDir string
+	var rich bool
+	var nested bool
+	var outFile string
+	out := &Output{}
+	var region string
+	var articlesFile string
+	var patchesFile string
+	var reportFile string
+	var workers int
+	var timeout time.Duration
+	fetcher := &Fetcher{Mode: CacheOnline}
+
+	fs.StringVar(&cutoff.StopAfter, "stop-after", "",
+		"Stop parsing after the article who's URL contains this string.")
+	fs.Func("since", "Stop parsing at the first article published before this date (YYYY-MM-DD).",
+		cutoff.Since.Set)
+	fs.Func("until", "Skip articles published after this date (YYYY-MM-DD).",
+		cutoff.Until.Set)
+	fs.StringVar(&sincePatch, "since-patch", "",
+		"Like -since, with the release date of this patch version.")
+	fs.IntVar(&cutoff.MaxPages, "max-pages", 5,
+		"Read at most this many pages of search results per search.")
+	fs.StringVar(&localeName, "locale", defaultLocale,
+		"Scrape the articles of this locale.")
+	fs.Var(flavorFilter, "flavor",
+		"Only output changes for these game flavors; a comma-separated list of\n"+
+			"'retail', 'wrath-classic', 'cataclysm-classic', 'classic-era', 'hardcore',\n"+
+			"'season-of-discovery', 'plunderstorm', or 'all'.")
+	fs.StringVar(&articlesFile, "articles", "articles.json",
+		"Read the article registry from this file.")
+	fs.StringVar(&patchesFile, "patches", "patches.json",
+		"Read the patch release calendar from this file.")
+	fs.StringVar(&region, "region", "us",
+		"Use the release dates of this region from the patch release calendar.")
+	fs.StringVar(&reportFile, "report", "",
+		"Write a JSON report of all errors to this file.")
+	fs.StringVar(&fetcher.CacheDir, "cache-dir", "",
+		"Cache fetched pages in this directory.")
+	fs.Var(&fetcher.Mode, "cache",
+		"Cache mode; one of 'online', 'cache-first', 'offline'. 'online' always fetches\n"+
+			"pages and updates the cache, 'cache-first' only fetches uncached pages and\n"+
+			"'offline' never fetches anything.")
+	fs.IntVar(&workers, "workers", 4,
+		"Scrape this many articles concurrently.")
+	fs.DurationVar(&timeout, "timeout", 5*time.Minute,
+		"Deadline for the entire run.")
+	fs.DurationVar(&fetcher.Timeout, "request-timeout", 30*time.Second,
+		"Timeout for a single HTTP request.")
+	fs.IntVar(&fetcher.Retries, "retries", 3,
+		"Retry requests failing with 429 or 5xx this many times.")
+	fs.DurationVar(&fetcher.Interval, "interval", 500*time.Millisecond,
+		"Minimum time between two requests to the same host.")
+	fs.BoolVar(&fetcher.Robots, "robots", false,
+		"Honor robots.txt.")
+	fs.StringVar(&mergeFile, "merge", "",
+		"Merge into this patch notes file. Only articles that are new or changed\n"+
+			"since the file was written are scraped again; all other changes are kept.")
+	fs.StringVar(&outFile, "o", "",
+		"Write the patch notes to this file instead of stdout. The file is replaced\n"+
+			"atomically, so it is never left half-written.")
+	fs.BoolVar(&out.Backup, "backup", false,
+		"Keep a timestamped copy of every file replaced by -o or -site-dir.")
+	fs.Float64Var(&out.MaxShrink, "max-shrink", 0.5,
+		"Refuse to replace a file if it would lose more than this fraction of its changes.")
+	fs.BoolVar(&out.Force, "force", false,
+		"Replace files regardless of -max-shrink and -allow-moves.")
+	fs.BoolVar(&out.AllowMoves, "allow-moves", false,
+		"Let changes move between the files of -site-dir, e.g. after changing the\n"+
+			"patch calendar.")
+	var classifierOpts classifierOptions
+	classifierOpts.register(fs)
+	fs.StringVar(&siteDir, "site-dir", "",
+		"Like -merge, for all patch notes files of the locale in this directory.\n"+
+			"Content update notes are written to the file of their patch, all other\n"+
+			"changes to the file of the patch that was live on their date.")
+	fs.BoolVar(&rich, "rich", false,
+		"Also write the text of scraped changes as HTML, keeping bold text and links,\n"+
+			"and the list of links in it.")
+	fs.BoolVar(&nested, "nested", false,
+		"Also write which changes are sub-points of other changes, as ParentID and\n"+
+			"Children.")
+
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
 
-	for _, u := range urls {
-		allChanges = scrapeURL(ctx, allChanges, u)
+	calendar, err := readCalendar(patchesFile, region)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	fixCasing(allChanges)
-	checkTags(allChanges)
+	classifier, err := classifierOpts.classifier()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	sort.SliceStable(allChanges, func(i, j int) bool {
-		return allChanges[i].Date > allChanges[j].Date
-	})
+	if cutoff.StopAfter == "" && cutoff.Since.IsZero() && sincePatch == "" {
+		log.Fatal("one of -stop-after, -since or -since-patch is required")
+	}
 
-	b, _ := json.MarshalIndent(struct {
-		Changes []Change
-	}{allChanges}, "", "  ")
+	if fetcher.Mode != CacheOnline && fetcher.CacheDir == "" {
+		log.Fatalf("-cache %s requires -cache-dir", fetcher.Mode)
+	}
 
-	fmt.Println(string(b))
-}
+	if siteDir != "" {
+		if mergeFile != "" {
+			log.Fatal("-site-dir and -merge are mutually exclusive")
+		}
+		if outFile != "" {
+			log.Fatal("-site-dir and -o are mutually exclusive")
+		}
+		if len(flavorFilter) != 1 || !flavorFilter[FlavorRetail] {
+			log.Fatal("-site-dir only supports -flavor retail")
+		}
+	}
 
-func collectPostURLs(ctx context.Context, urls []string, indexURL string, stopAfter string) []string {
-	req, err := http.NewRequestWithContext(ctx, "GET", indexURL, nil)
+	loc, err := lookupLocale(localeName)
 	if err != nil {
 		log.Fatal(err)
 	}
-	req.Header.Set("User-Agent", userAgent)
 
-	res, err := http.DefaultClient.Do(req)
+	registry, err := readRegistry(articlesFile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer res.Body.Close()
-	defer io.Copy(io.Discard, res.Body)
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Fatal(err)
+	if sincePatch != "" {
+		d, ok := calendar.ReleaseDate(sincePatch)
+		if !ok {
+			d, ok = registry.ReleaseDate(sincePatch)
+		}
+		if !ok {
+			log.Fatalf("-since-patch: %s is neither in %s nor in %s", sincePatch, patchesFile, articlesFile)
+		}
+		cutoff.Since = Date{d}
 	}
 
-	doc.Find(".NewsBlog-link").EachWithBreak(func(i int, s *goquery.Selection) bool {
-		href, _ := s.Attr("href")
-		u, err := url.Parse(href)
+	prev := &PatchNotes{}
+	var site *SiteDir
+	if mergeFile != "" {
+		prev, err = readPatchNotes(mergeFile)
 		if err != nil {
-			log.Fatal("invalid URL in href")
+			log.Fatal(err)
 		}
+	}
+	if siteDir != "" {
+		site, err = readSiteDir(siteDir, loc.Name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		prev = site.Union()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-		absURL := res.Request.URL.ResolveReference(u).String()
+	report := &Report{}
 
-		if !sliceContains(urls, absURL) {
-			urls = append(urls, absURL)
+	urls, cutoffReached := collectSearches(ctx, fetcher, loc.SearchURLs(), cutoff, report)
+	if !cutoffReached {
+		report.Add("", StageCollect, fmt.Errorf("%s not reached within %d pages of search results", cutoff, cutoff.MaxPages))
+	}
+
+	if len(urls) == 0 {
+		report.Write(reportFile)
+		log.Println("no articles found")
+		os.Exit(exitFailure)
+	}
+
+	allChanges := make([]Change, 0, 5000)
+	articles := make([]Article, 0, len(urls))
+
+	for _, r := range scrapeArticles(ctx, fetcher, registry, classifier, prev, urls, workers) {
+		if r.Err != nil {
+			report.Add(r.URL, r.Stage, r.Err)
+			continue
 		}
 
-		return !strings.Contains(href, stopAfter)
-	})
+		allChanges = append(allChanges, r.Changes...)
+		articles = append(articles, r.Article)
+	}
+	if err := classifierOpts.finish(); err != nil {
+		log.Println(err)
+	}
+	if !rich {
+		dropRich(allChanges)
+	}
+	if !nested {
+		dropNesting(allChanges)
+	}
 
-	return urls
-}
+	// Articles with tags that can't be fixed are dropped entirely, keeping
+	// their previous changes, if any. Only the flavors being written count.
+	allChanges = flavorFilter.Filter(allChanges)
+	if err := fixCasing(allChanges); err != nil {
+		report.AddAll(err)
+	}
+	failed := report.URLs()
+	allChanges = slices.DeleteFunc(allChanges, func(c Change) bool { return failed[c.URL] })
+	articles = slices.DeleteFunc(articles, func(a Article) bool { return failed[a.URL] })
+
+	scraped := map[string]bool{}
+	for _, a := range articles {
+		if !prev.hasArticle(a) {
+			scraped[a.URL] = true
+		}
+	}
 
-func sliceContains(xs []string, x string) bool {
-	for _, a := range xs {
-		if a == x {
-			return true
+	if len(articles) == 0 {
+		report.Write(reportFile)
+		log.Println("all articles failed")
+		os.Exit(exitFailure)
+	}
+
+	if site != nil {
+		// Versions are assigned after merging, so that only content
+		// updates are routed by version.
+		if err := site.Merge(calendar, articles, scraped, allChanges); err != nil {
+			report.AddAll(err)
+		}
+		for _, notes := range site.Files {
+			notes.Changes = finishChanges(notes.Changes, calendar, flavorFilter)
+		}
+		if err := site.Check(out); err != nil {
+			report.AddAll(err)
+			report.Write(reportFile)
+			log.Fatal("refusing to write; use -force to write anyway")
+		}
+		if err := site.Write(out); err != nil {
+			report.Write(reportFile)
+			log.Fatal(err)
 		}
+	} else {
+		notes := mergePatchNotes(prev, articles, scraped, allChanges)
+		notes.Changes = finishChanges(notes.Changes, calendar, flavorFilter)
+
+		if outFile == "" {
+			printPatchNotes(notes)
+		} else {
+			if err := out.Check(outFile, notes); err != nil {
+				report.AddAll(err)
+				report.Write(reportFile)
+				log.Fatal("refusing to write; use -force to write anyway")
+			}
+			if err := out.Write(outFile, notes); err != nil {
+				report.Write(reportFile)
+				log.Fatal(err)
+			}
+		}
+	}
+
+	report.Write(reportFile)
+	if len(report.Errors) > 0 {
+		log.Printf("%d errors; see above", len(report.Errors))
+		os.Exit(exitPartial)
 	}
-	return false
 }
 
-func scrapeURL(ctx context.Context, dest []Change, u string) []Change {
+// finishChanges filters changes by flavor, fills in versions and IDs, checks
+// their tags and sorts them by date, newest first.
+func finishChanges(changes []Change, calendar *Calendar, filter FlavorFilter) []Change {
+	changes = filter.Filter(changes)
+	calendar.assignVersions(changes)
+	ensureIDs(changes)
+
+	checkTags(changes)
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Date > changes[j].Date
+	})
+
+	return changes
+}
+
+func fetchDocument(ctx context.Context, f *Fetcher, u string) (*goquery.Document, error) {
 	log.Println(u)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	page, err := f.Get(ctx, u)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	req.Header.Set("User-Agent", userAgent)
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer res.Body.Close()
+	return page.Document()
+}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Fatal(err)
+func scrapeDocument(dest []Change, doc *goquery.Document, registry *Registry, c Classifier) ([]Change, error) {
+	a, ok := registry.Lookup(doc.Url)
+	if !ok {
+		return dest, fmt.Errorf("unrecognizable URL: %s", doc.Url)
 	}
-	doc.Url = res.Request.URL
 
-	if strings.Contains(u, "/hotfixes-") {
-		dest = scrapeHotfixes(dest, doc)
-		return dest
+	switch a.Kind {
+	case KindHotfixes:
+		return scrapeHotfixes(dest, doc, c)
+	case KindContentUpdate:
+		return scrapeContentUpdate(dest, doc, c, a.FirstHeader, a.Version, a.date)
+	default:
+		return dest, nil
 	}
+}
 
-	if strings.Contains(u, "/24066682/") {
-		dest = scrapeContentUpdate(dest, doc, "#item2", "10.2.7",
-			time.Date(2024, 4, 19, 0, 0, 0, 0, time.UTC))
-		return dest
+func scrapeContentUpdate(dest []Change, doc *goquery.Document, c Classifier, firstHeader, version string, date time.Time) ([]Change, error) {
+	root, err := articleRoot(doc, firstHeader)
+	if err != nil {
+		return dest, err
 	}
 
-	if strings.Contains(u, "/24066683/") {
-		// Cosmetic updates only.
-		return dest
+	start := len(dest)
+	dest, err = scrapeHTML(dest, root, doc, c, date, []string{version})
+	for i := range dest[start:] {
+		dest[start+i].Version = version
 	}
 
-	log.Fatalf("Unrecognizable URL: %s", u)
+	return dest, err
+}
+
+func scrapeHotfixes(dest []Change, doc *goquery.Document, c Classifier) ([]Change, error) {
+	root, err := articleRoot(doc, "")
+	if err != nil {
+		return dest, err
+	}
 
-	return dest
+	return scrapeHTML(dest, root, doc, c, time.Time{}, nil)
 }
 
-func scrapeContentUpdate(dest []Change, doc *goquery.Document, firstHeader, version string, date time.Time) []Change {
-	changeSets := doc.Find(".Blog .detail " + firstHeader)
+// articleRoot returns the node containing the article body. If firstHeader is
+// not empty, everything before the first element matching it is removed, and
+// its parent is returned instead.
+func articleRoot(doc *goquery.Document, firstHeader string) (*html.Node, error) {
+	sel := ".Blog .detail"
+	if firstHeader != "" {
+		sel += " " + firstHeader
+	}
 
+	changeSets := doc.Find(sel)
 	if len(changeSets.Nodes) == 0 {
-		log.Fatal("missing .Blog .detail " + firstHeader)
+		return nil, errors.New("missing " + sel)
 	}
 	if len(changeSets.Nodes) > 1 {
-		log.Fatal("multiple .Blog .detail " + firstHeader)
+		return nil, errors.New("multiple " + sel)
+	}
+
+	if firstHeader == "" {
+		return changeSets.Nodes[0], nil
 	}
 
 	header := changeSets.Nodes[0]
-	root := header.Parent
 	for header.PrevSibling != nil {
 		header.Parent.RemoveChild(header.PrevSibling)
 	}
 
-	return scrapeHTML(dest, root, doc, date, []string{version})
+	return header.Parent, nil
 }
 
-func scrapeHotfixes(dest []Change, doc *goquery.Document) []Change {
-	changeSets := doc.Find(".Blog .detail")
+func scrapeHTML(dest []Change, root *html.Node, doc *goquery.Document, c Classifier, date time.Time, tags []string) ([]Change, error) {
+	start := len(dest)
 
-	if len(changeSets.Nodes) == 0 {
-		log.Fatal("missing .Blog .detail")
-	}
-	if len(changeSets.Nodes) > 1 {
-		log.Fatal("multiple .Blog .detail")
+	loc := localeOf(doc.Url)
+	var uStr string
+	if doc.Url != nil {
+		uStr = articleURL(doc.Url)
 	}
 
-	return scrapeHTML(dest, changeSets.Nodes[0], doc, time.Time{}, nil)
-}
+	tree := buildTree(root, doc.Url, loc, classifierFor(c, uStr))
 
-func scrapeHTML(dest []Change, root *html.Node, doc *goquery.Document, date time.Time, tags []string) []Change {
-	tree := buildTree(root)
-
-	var uStr string
-	if srcURL := doc.Url; srcURL != nil {
-		u := new(url.URL)
-		*u = *srcURL
-		u.Path = filepath.Dir(u.Path)
-		uStr = u.String()
-	}
+	ids := map[string]int{}
 
 	var category string
 	for _, n := range tree.Children {
 		if !date.IsZero() && category != "" {
 			switch n.Type {
 			case TypeTag, TypeChange, TypeUnclassified:
-				dest = collectChanges(dest, n, append(tags, cleanTag(category)...), date, uStr)
+				var err error
+				dest, err = collectChanges(dest, n, append(tags, cleanTag(category)...), date, uStr, ids)
+				if err != nil {
+					return dest, err
+				}
 				category = ""
 			default:
-				log.Fatalf("unexpected %s; want one of 'tag', 'change', 'unclassified'", n.Type.String())
+				return dest, fmt.Errorf("unexpected %s; want one of 'tag', 'change', 'unclassified'", n.Type.String())
 			}
 		}
 
 		switch n.Type {
 		case TypeDate:
 			var err error
-			date, err = time.Parse("January 2, 2006", n.Text)
+			date, err = loc.ParseDate(n.Text)
 			if err != nil {
-				panic(fmt.Sprintf("date miss-classified: %s: %v", n.Text, err))
+				return dest, fmt.Errorf("date miss-classified: %s: %w", n.Text, err)
 			}
 		case TypeTag:
 			if !date.IsZero() {
@@ -245,86 +534,95 @@ func scrapeHTML(dest []Change, root *html.Node, doc *goquery.Document, date time
 		}
 	}
 
-	return dest
+	loc.canonicalTags(dest[start:])
+	for i := start; i < len(dest); i++ {
+		dest[i].Flavor = loc.flavorOf(dest[i].Tags)
+	}
+
+	return dest, nil
+}
+
+// articleURL returns the canonical URL of the article at u, i.e. without the
+// trailing slug.
+func articleURL(u *url.URL) string {
+	a := new(url.URL)
+	*a = *u
+	a.Path = filepath.Dir(a.Path)
+	return a.String()
 }
 
-func buildTree(root *html.Node) *Tree {
+func buildTree(root *html.Node, base *url.URL, loc *Locale, c Classifier) *Tree {
 	tree := &Tree{
-		Children: CollectTexts(root),
+		Children: CollectTexts(root, base),
 	}
 
 	tree.Prune(true)
 	tree.Walk(func(n *Tree) {
 		if n.Text != "" {
 			n.Text = strings.TrimSpace(n.Text)
+			n.HTML = strings.TrimSpace(n.HTML)
 		}
 	})
 	tree.Prune(false)
 	tree.Walk(func(n *Tree) {
-		n.Type = Classify(n)
+		cl := c.Classify(n, loc)
+		n.Type, n.Rule, n.Confidence = cl.Type, cl.Rule, cl.Confidence
 	})
 
 	return tree
 }
 
-func collectChanges(dest []Change, tree *Tree, tags []string, date time.Time, srcURL string) []Change {
-	return append(dest, flattenChanges(tree, tags, date, srcURL)...)
+func collectChanges(dest []Change, tree *Tree, tags []string, date time.Time, srcURL string, ids map[string]int) ([]Change, error) {
+	nest := newNesting()
+	changes, err := flattenChanges(tree, tags, date, srcURL, ids, nest)
+	nest.finish(changes)
+	return append(dest, changes...), err
 }
 
-func flattenChanges(root *Tree, tags []string, date time.Time, srcURL string) []Change {
-	for _, t := range tags {
-		if strings.Contains(t, "WotLK") {
-			return nil
-		}
-		if strings.Contains(t, "WoW Classic Hardcore") {
-			return nil
-		}
-		if strings.Contains(t, "Classic Era") {
-			return nil
-		}
-		if strings.Contains(t, "Cataclysm Classic") {
-			return nil
-		}
-		if strings.Contains(t, "Wrath Classic") {
-			return nil
-		}
-		if strings.Contains(t, "Wrath of the Lich King Classic") {
-			return nil
-		}
-		if strings.Contains(t, "Plunderstorm") {
-			return nil
-		}
-		if strings.Contains(t, "Season of Discovery") {
-			return nil
-		}
-	}
-
+func flattenChanges(root *Tree, tags []string, date time.Time, srcURL string, ids map[string]int, nest *nesting) ([]Change, error) {
 	var changes []Change
 
+	addTag := func(n *Tree) {
+		tags = append(tags, cleanTag(n.Text)...)
+		nest.add(n.Depth, "")
+	}
+
 	addChange := func(n *Tree, tags []string) {
-		text := n.Text
+		text, rich := n.Text, n.HTML
 
 		ts := make([]string, 0, len(tags))
 		for _, t := range tags {
 			if strings.HasPrefix(t, "__text_prefix ") {
-				text = strings.TrimPrefix(t, "__text_prefix ") + text
+				prefix := strings.TrimPrefix(t, "__text_prefix ")
+				text = prefix + text
+				rich = htmlEscaper.Replace(prefix) + rich
 			} else {
 				ts = append(ts, t)
 			}
 		}
 
+		// Most notes have no formatting at all.
+		if rich == htmlEscaper.Replace(text) {
+			rich = ""
+		}
+
+		id := nextID(ids, srcURL, date, text)
 		changes = append(changes, Change{
-			Date:    date.Format(time.DateOnly),
-			Weekday: date.Weekday().String(),
-			URL:     srcURL,
-			Tags:    ts,
-			Text:    text,
+			ID:       id,
+			Date:     date.Format(time.DateOnly),
+			Weekday:  date.Weekday().String(),
+			URL:      srcURL,
+			Tags:     ts,
+			Text:     text,
+			HTML:     rich,
+			Links:    n.Links,
+			ParentID: nest.add(n.Depth, id),
 		})
 	}
 
 	switch root.Type {
 	case TypeTag:
-		tags = append(tags, cleanTag(root.Text)...)
+		addTag(root)
 	case TypeChange:
 		addChange(root, tags)
 	}
@@ -332,38 +630,162 @@ func flattenChanges(root *Tree, tags []string, date time.Time, srcURL string) []
 	for _, n := range root.Children {
 		switch n.Type {
 		case TypeTag:
-			tags = append(tags, cleanTag(n.Text)...)
+			addTag(n)
 		case TypeUnclassified:
-			changes = append(changes, flattenChanges(n, tags, date, srcURL)...)
+			cs, err := flattenChanges(n, tags, date, srcURL, ids, nest)
+			changes = append(changes, cs...)
+			if err != nil {
+				return changes, err
+			}
 		case TypeChange:
 			addChange(n, tags)
 		default:
-			log.Fatalf("unexpected %s; want one of 'tag', 'change', 'unclassified'", n.Type.String())
+			return changes, fmt.Errorf("unexpected %s; want one of 'tag', 'change', 'unclassified'", n.Type.String())
 		}
 	}
 
-	return changes
+	return changes, nil
+}
+
+// dropRich removes the HTML and links of changes.
+func dropRich(changes []Change) {
+	for i := range changes {
+		changes[i].HTML, changes[i].Links = "", nil
+	}
 }
 
-func debug(args []string) []Change {
-	fname := args[0]
+func runParseFile(args []string) {
+	fs := flag.NewFlagSet("parse-file", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes parse-file [flags] FILE")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Scrape an article saved as HTML and print its changes in the same format")
+		fmt.Fprintln(fs.Output(), "as scrape. The article is parsed as hotfixes unless -first-header or")
+		fmt.Fprintln(fs.Output(), "-url select a content update.")
+		fs.PrintDefaults()
+	}
 
-	f, err := os.Open(fname)
+	var srcURL string
+	var firstHeader string
+	var version string
+	date := Date{time.Now()}
+	flavorFilter := FlavorFilter{FlavorRetail: true}
+	var articlesFile string
+	var patchesFile string
+	var region string
+	var rich bool
+	var nested bool
+
+	fs.StringVar(&srcURL, "url", "",
+		"The URL the article was saved from. It determines the locale and the\n"+
+			"URL of the changes, and selects the rule in the article registry.")
+	fs.StringVar(&firstHeader, "first-header", "",
+		"Parse the article as content update, starting at the element matching\n"+
+			"this selector. Requires -version.")
+	fs.StringVar(&version, "version", "",
+		"Patch version of the content update.")
+	fs.Var(&date, "date",
+		"Release date of the content update (YYYY-MM-DD).")
+	fs.Var(flavorFilter, "flavor",
+		"Only output changes for these game flavors; see 'scrape -h'.")
+	fs.StringVar(&articlesFile, "articles", "articles.json",
+		"Read the article registry from this file.")
+	fs.StringVar(&patchesFile, "patches", "patches.json",
+		"Read the patch release calendar from this file.")
+	fs.StringVar(&region, "region", "us",
+		"Use the release dates of this region from the patch release calendar.")
+	fs.BoolVar(&rich, "rich", false,
+		"Also output the text of changes as HTML; see 'scrape -h'.")
+	fs.BoolVar(&nested, "nested", false,
+		"Also output which changes are sub-points of other changes; see 'scrape -h'.")
+	var classifierOpts classifierOptions
+	classifierOpts.register(fs)
+
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if (firstHeader == "") != (version == "") {
+		log.Fatal("-first-header and -version must be used together")
+	}
+
+	calendar, err := readCalendar(patchesFile, region)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	classifier, err := classifierOpts.classifier()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Open(fs.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
 	doc, err := goquery.NewDocumentFromReader(f)
+	f.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	notes := &PatchNotes{}
+	if srcURL != "" {
+		doc.Url, err = url.Parse(srcURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		notes.Articles = []Article{{URL: articleURL(doc.Url), Hash: contentHash(doc)}}
+	}
+
 	dest := make([]Change, 0, 5000)
+	switch {
+	case firstHeader != "":
+		notes.Changes, err = scrapeContentUpdate(dest, doc, classifier, firstHeader, version, date.Time)
+	case srcURL != "":
+		var registry *Registry
+		registry, err = readRegistry(articlesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		notes.Changes, err = scrapeDocument(dest, doc, registry, classifier)
+	default:
+		notes.Changes, err = scrapeHotfixes(dest, doc, classifier)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := classifierOpts.finish(); err != nil {
+		log.Println(err)
+	}
+	if !rich {
+		dropRich(notes.Changes)
+	}
+	if !nested {
+		dropNesting(notes.Changes)
+	}
 
-	if len(args) > 1 {
-		firstHeader := args[1]
-		versionTag := args[2]
-		return scrapeContentUpdate(dest, doc, firstHeader, versionTag, time.Now())
-	} else {
-		return scrapeHotfixes(dest, doc)
+	notes.Changes, err = publishChanges(notes.Changes, calendar, flavorFilter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printPatchNotes(notes)
+}
+
+// publishChanges turns the changes of a single article into what is written
+// to the patch notes files: it drops other flavors, fixes the casing of tags
+// and calls finishChanges.
+func publishChanges(changes []Change, calendar *Calendar, filter FlavorFilter) ([]Change, error) {
+	changes = filter.Filter(changes)
+	if err := fixCasing(changes); err != nil {
+		return changes, err
 	}
+	return finishChanges(changes, calendar, filter), nil
+}
+
+func printPatchNotes(notes *PatchNotes) {
+	b, _ := json.MarshalIndent(notes, "", "  ")
+	fmt.Println(string(b))
 }
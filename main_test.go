@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata.")
+
+// TestScrapeGolden scrapes the articles in testdata and compares the retail
+// changes, as they are published, with the golden .json file next to each
+// article. Run with -update to regenerate the golden files after an intended
+// change, and review the diff.
+//
+// The articles are reduced by hand to the markup of the article body. To add
+// a saved page, fetch it with "scrape -cache-dir", copy the Body of the
+// cached page, strip its <script> and <style> elements but nothing else, and
+// add it to the table below.
+//
+// All classifiers must agree on the corpus.
+func TestScrapeGolden(t *testing.T) {
+	calendar, err := readCalendar("patches.json", "us")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phrases, err := readPhrases("phrases.json", HeuristicClassifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := readBayesModel("model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	classifiers := []struct {
+		name string
+		c    Classifier
+	}{
+		{"heuristic", HeuristicClassifier{}},
+		{"phrases", phrases},
+		{"bayes", &BayesClassifier{Model: model, Base: HeuristicClassifier{}, MinProbability: bayesMinProbability}},
+	}
+
+	tests := []struct {
+		file string
+		url  string
+
+		// Set for content updates.
+		firstHeader string
+		version     string
+		date        string
+	}{
+		{
+			file: "hotfixes-dragonflight.html",
+			url:  "https://worldofwarcraft.blizzard.com/en-us/news/24057474/dragonflight-hotfixes",
+		},
+		{
+			file: "hotfixes-flavors.html",
+			url:  "https://worldofwarcraft.blizzard.com/en-us/news/24066687/hotfixes-july-3-2024",
+		},
+		{
+			file: "hotfixes-de-de.html",
+			url:  "https://worldofwarcraft.blizzard.com/de-de/news/24066687/hotfixes-3-juli-2024",
+		},
+		{
+			file:        "content-update-10.2.7.html",
+			url:         "https://worldofwarcraft.blizzard.com/en-us/news/24066682/dragonflight-season-4-content-update-notes",
+			firstHeader: "#item2",
+			version:     "10.2.7",
+			date:        "2024-04-19",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, c := range classifiers {
+			name := strings.TrimSuffix(tt.file, ".html") + "/" + c.name
+			t.Run(name, func(t *testing.T) {
+				doc := readTestDocument(t, filepath.Join("testdata", tt.file), tt.url)
+
+				var changes []Change
+				var err error
+				if tt.firstHeader != "" {
+					date, _ := time.Parse(time.DateOnly, tt.date)
+					changes, err = scrapeContentUpdate(nil, doc, c.c, tt.firstHeader, tt.version, date)
+				} else {
+					changes, err = scrapeHotfixes(nil, doc, c.c)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				changes, err = publishChanges(changes, calendar, FlavorFilter{FlavorRetail: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := marshalPatchNotes(&PatchNotes{Changes: changes})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				golden := filepath.Join("testdata", strings.TrimSuffix(tt.file, ".html")+".json")
+				if *update && c.name == "heuristic" {
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v; run go test -update to create it", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("changes differ from %s; run go test -update and review the diff", golden)
+				}
+			})
+		}
+	}
+}
+
+func readTestDocument(t *testing.T, fname, u string) *goquery.Document {
+	t.Helper()
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Url, err = url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PatchNotes is the document written to site/*.json.
+type PatchNotes struct {
+	// Articles lists every article that contributed to Changes, together
+	// with a hash of its content at the time it was scraped.
+	Articles []Article `json:",omitempty"`
+	Changes  []Change
+}
+
+type Article struct {
+	URL  string
+	Hash string
+}
+
+// readPatchNotes reads a previously written patch notes file. A missing file
+// is not an error and results in empty patch notes.
+func readPatchNotes(fname string) (*PatchNotes, error) {
+	notes := &PatchNotes{}
+
+	f, err := os.Open(fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return notes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(notes)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// hasArticle reports whether a has been scraped before and its content hasn't
+// changed since.
+func (p *PatchNotes) hasArticle(a Article) bool {
+	for _, b := range p.Articles {
+		if b.URL == a.URL {
+			return b.Hash != "" && b.Hash == a.Hash
+		}
+	}
+	return false
+}
+
+// contentHash returns a hash of the article body in doc. It must be called
+// before the document is scraped, because scraping may modify the document.
+func contentHash(doc *goquery.Document) string {
+	h, _ := goquery.OuterHtml(doc.Find(".Blog .detail"))
+
+	sum := sha256.Sum256([]byte(h))
+	return hex.EncodeToString(sum[:])
+}
+
+// mergePatchNotes replaces the changes of all scraped articles in prev with
+// changes. Changes of articles that haven't been scraped again, either because
+// they are unchanged or no longer listed, are kept.
+func mergePatchNotes(prev *PatchNotes, articles []Article, scraped map[string]bool, changes []Change) *PatchNotes {
+	merged := &PatchNotes{
+		Changes: changes,
+	}
+
+	seen := map[string]bool{}
+	for _, a := range articles {
+		if !seen[a.URL] {
+			merged.Articles = append(merged.Articles, a)
+			seen[a.URL] = true
+		}
+	}
+	for _, a := range prev.Articles {
+		if !seen[a.URL] {
+			merged.Articles = append(merged.Articles, a)
+			seen[a.URL] = true
+		}
+	}
+
+	for _, c := range prev.Changes {
+		if !scraped[c.URL] {
+			merged.Changes = append(merged.Changes, c)
+		}
+	}
+
+	return merged
+}
+
+func writePatchNotes(fname string, notes *PatchNotes) error {
+	b, err := marshalPatchNotes(notes)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fname, b)
+}
@@ -0,0 +1,54 @@
+package main
+
+// nesting derives the hierarchy of changes from the depth of the lists their
+// texts are in: a change in a list is the parent of the changes in the lists
+// nested below it, e.g. of its developers' notes. Changes that are not in a
+// list, such as paragraphs of content updates, are never parents.
+type nesting struct {
+	stack    []nestedText
+	children map[string][]string
+}
+
+type nestedText struct {
+	depth int
+	id    string // empty for tags
+}
+
+func newNesting() *nesting {
+	return &nesting{children: map[string][]string{}}
+}
+
+// add records the next text in document order, at the given depth, and
+// returns the ID of the parent of the change with the given ID, if any. Tags
+// are added with an empty id; they end the lists of the changes before them.
+func (s *nesting) add(depth int, id string) string {
+	for len(s.stack) > 0 && s.stack[len(s.stack)-1].depth >= depth {
+		s.stack = s.stack[:len(s.stack)-1]
+	}
+
+	var parentID string
+	if n := len(s.stack); n > 0 && id != "" && s.stack[n-1].id != "" {
+		parentID = s.stack[n-1].id
+		s.children[parentID] = append(s.children[parentID], id)
+	}
+
+	if depth > 0 {
+		s.stack = append(s.stack, nestedText{depth, id})
+	}
+
+	return parentID
+}
+
+// finish sets the Children of changes.
+func (s *nesting) finish(changes []Change) {
+	for i, c := range changes {
+		changes[i].Children = s.children[c.ID]
+	}
+}
+
+// dropNesting removes the ParentID and Children of changes.
+func dropNesting(changes []Change) {
+	for i := range changes {
+		changes[i].ParentID, changes[i].Children = "", nil
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Output writes patch notes files.
+type Output struct {
+	// Backup keeps a timestamped copy of every file that is replaced.
+	Backup bool
+	// MaxShrink is the largest fraction of changes a file may lose. Writes
+	// that would lose more are refused unless Force is set, because they
+	// usually mean that scraping broke, not that Blizzard deleted notes.
+	MaxShrink float64
+	Force     bool
+	// AllowMoves lets changes move between the files of a SiteDir.
+	AllowMoves bool
+}
+
+// Check returns an error if writing notes to fname would be refused.
+func (o *Output) Check(fname string, notes *PatchNotes) error {
+	old, err := readPatchNotes(fname)
+	if err != nil {
+		return err
+	}
+
+	return o.check(fname, old, notes)
+}
+
+// check compares notes with old, the current content of the file called name,
+// and returns an error if it lost too many changes, either in total or in any
+// of its articles.
+func (o *Output) check(name string, old, notes *PatchNotes) error {
+	return errors.Join(o.problems(name, old, notes)...)
+}
+
+// problems returns the errors of check.
+func (o *Output) problems(name string, old, notes *PatchNotes) []error {
+	if o.Force {
+		return nil
+	}
+
+	var errs []error
+	if err := o.shrinks(name, old, notes); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, validateNotes(old, notes, o.MaxShrink)...)
+
+	return errs
+}
+
+// shrinks returns an error if notes has lost more than MaxShrink of the
+// changes in old.
+func (o *Output) shrinks(name string, old, notes *PatchNotes) error {
+	n, m := len(old.Changes), len(notes.Changes)
+	if n > 0 && float64(n-m) > o.MaxShrink*float64(n) {
+		return &ScrapeError{Stage: StageValidate,
+			Err: fmt.Errorf("%s: shrinks from %d to %d changes", name, n, m)}
+	}
+	return nil
+}
+
+// Write replaces fname with notes, unless the content is unchanged. It does
+// not call Check.
+func (o *Output) Write(fname string, notes *PatchNotes) error {
+	b, err := marshalPatchNotes(notes)
+	if err != nil {
+		return err
+	}
+
+	old, err := os.ReadFile(fname)
+	switch {
+	case err == nil && bytes.Equal(old, b):
+		return nil
+	case err == nil && o.Backup:
+		bak := fname + "." + time.Now().UTC().Format("20060102T150405Z") + ".bak"
+		if err := os.WriteFile(bak, old, 0o644); err != nil {
+			return err
+		}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	log.Println("writing", fname)
+	return writeFileAtomic(fname, b)
+}
+
+func marshalPatchNotes(notes *PatchNotes) ([]byte, error) {
+	b, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// writeFileAtomic writes b to a temporary file next to fname and renames it to
+// fname, so readers never see a partially written file.
+func writeFileAtomic(fname string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(fname), "."+filepath.Base(fname)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), fname)
+}
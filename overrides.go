@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Override forces the type of a text, for when the classifiers get it wrong.
+// It matches either by Text, optionally limited to Article, or by Hash and
+// Article, as listed in the low-confidence report.
+type Override struct {
+	Text    string `json:",omitempty"`
+	Hash    string `json:",omitempty"` // see textHash
+	Article string `json:",omitempty"` // as in Change.URL
+	Type    TextType
+	Comment string `json:",omitempty"`
+}
+
+func (o *Override) matches(text, hash, article string) bool {
+	if o.Article != "" && o.Article != article {
+		return false
+	}
+	if o.Text != "" {
+		return o.Text == text
+	}
+	return o.Hash == hash
+}
+
+// textHash returns a short hash of text that identifies it in overrides.
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// readOverrides reads the overrides in fname. A missing file is not an error
+// and results in no overrides.
+func readOverrides(fname string) ([]*Override, error) {
+	b, err := os.ReadFile(fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var v struct {
+		Overrides []*Override
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+
+	for i, o := range v.Overrides {
+		switch {
+		case o.Type != TypeTag && o.Type != TypeChange && o.Type != TypeDate:
+			return nil, fmt.Errorf("%s: override %d: type must be %q, %q or %q", fname, i, TypeTag, TypeChange, TypeDate)
+		case o.Text == "" && (o.Hash == "" || o.Article == ""):
+			return nil, fmt.Errorf("%s: override %d: want Text, or Hash and Article", fname, i)
+		}
+	}
+
+	return v.Overrides, nil
+}
+
+// OverrideClassifier applies Overrides to the decisions of Base and records
+// all decisions with a confidence below MinConfidence for review.
+//
+// Overrides may be limited to an article, so the classifier must be bound to
+// the article being scraped with forArticle.
+type OverrideClassifier struct {
+	Base          Classifier
+	Overrides     []*Override
+	MinConfidence float64
+
+	article string
+	review  *reviewLog
+}
+
+type reviewLog struct {
+	mu        sync.Mutex
+	decisions map[reviewKey]Decision
+}
+
+type reviewKey struct {
+	article, text string
+}
+
+// Decision is an entry of the low-confidence report.
+type Decision struct {
+	Article    string
+	Text       string
+	Hash       string
+	Type       TextType
+	Rule       string
+	Confidence float64
+}
+
+func newOverrideClassifier(base Classifier, overrides []*Override, minConfidence float64) *OverrideClassifier {
+	return &OverrideClassifier{
+		Base:          base,
+		Overrides:     overrides,
+		MinConfidence: minConfidence,
+		review:        &reviewLog{decisions: map[reviewKey]Decision{}},
+	}
+}
+
+// forArticle returns a copy of c for the article with the given URL, sharing
+// the low-confidence decisions with c.
+func (c *OverrideClassifier) forArticle(article string) *OverrideClassifier {
+	cc := *c
+	cc.article = article
+	return &cc
+}
+
+// classifierFor binds c to the article with the given URL, if necessary.
+func classifierFor(c Classifier, article string) Classifier {
+	if oc, ok := c.(*OverrideClassifier); ok {
+		return oc.forArticle(article)
+	}
+	return c
+}
+
+func (c *OverrideClassifier) Classify(t *Tree, loc *Locale) Classification {
+	if len(t.Children) > 0 {
+		return c.Base.Classify(t, loc)
+	}
+
+	hash := textHash(t.Text)
+	for _, o := range c.Overrides {
+		if o.matches(t.Text, hash, c.article) {
+			return Classification{o.Type, "override", 1}
+		}
+	}
+
+	cl := c.Base.Classify(t, loc)
+	if cl.Confidence < c.MinConfidence {
+		c.review.mu.Lock()
+		c.review.decisions[reviewKey{c.article, t.Text}] = Decision{
+			Article:    c.article,
+			Text:       t.Text,
+			Hash:       hash,
+			Type:       cl.Type,
+			Rule:       cl.Rule,
+			Confidence: cl.Confidence,
+		}
+		c.review.mu.Unlock()
+	}
+
+	return cl
+}
+
+// LowConfidence returns all decisions with a confidence below MinConfidence,
+// least confident first.
+func (c *OverrideClassifier) LowConfidence() []Decision {
+	c.review.mu.Lock()
+	defer c.review.mu.Unlock()
+
+	ds := make([]Decision, 0, len(c.review.decisions))
+	for _, d := range c.review.decisions {
+		ds = append(ds, d)
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		if ds[i].Confidence != ds[j].Confidence {
+			return ds[i].Confidence < ds[j].Confidence
+		}
+		if ds[i].Article != ds[j].Article {
+			return ds[i].Article < ds[j].Article
+		}
+		return ds[i].Text < ds[j].Text
+	})
+
+	return ds
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type ArticleKind string
+
+const (
+	KindHotfixes      ArticleKind = "hotfixes"
+	KindContentUpdate ArticleKind = "content-update"
+	KindSkip          ArticleKind = "skip"
+)
+
+// Registry maps articles to the way they are scraped. It is read from
+// articles.json so that new content update notes can be added without
+// touching the code.
+type Registry struct {
+	Articles []*ArticleRule
+}
+
+// ArticleRule matches articles either by their numeric ID or by a regular
+// expression applied to the article URL. The first matching rule wins.
+type ArticleRule struct {
+	ID      string `json:",omitempty"`
+	Pattern string `json:",omitempty"`
+	Comment string `json:",omitempty"`
+
+	Kind ArticleKind
+
+	// FirstHeader, Version and Date apply to content updates only.
+	// FirstHeader selects the first header of the actual notes;
+	// everything before it is ignored.
+	FirstHeader string `json:",omitempty"`
+	Version     string `json:",omitempty"`
+	Date        string `json:",omitempty"`
+
+	pattern *regexp.Regexp
+	date    time.Time
+}
+
+func readRegistry(fname string) (*Registry, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+
+	for i, a := range r.Articles {
+		if err := a.init(); err != nil {
+			return nil, fmt.Errorf("%s: article %d: %w", fname, i, err)
+		}
+	}
+
+	return r, nil
+}
+
+func (a *ArticleRule) init() error {
+	if a.ID == "" && a.Pattern == "" {
+		return fmt.Errorf("one of ID or Pattern is required")
+	}
+
+	if a.Pattern != "" {
+		var err error
+		a.pattern, err = regexp.Compile(a.Pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch a.Kind {
+	case KindHotfixes, KindSkip:
+	case KindContentUpdate:
+		if a.FirstHeader == "" || a.Version == "" || a.Date == "" {
+			return fmt.Errorf("content updates require FirstHeader, Version and Date")
+		}
+
+		var err error
+		a.date, err = time.Parse(time.DateOnly, a.Date)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown kind %q; want one of %q, %q, %q",
+			a.Kind, KindHotfixes, KindContentUpdate, KindSkip)
+	}
+
+	return nil
+}
+
+// Lookup returns the first rule matching u.
+func (r *Registry) Lookup(u *url.URL) (*ArticleRule, bool) {
+	for _, a := range r.Articles {
+		if a.matches(u) {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+func (a *ArticleRule) matches(u *url.URL) bool {
+	if a.ID != "" {
+		for _, seg := range strings.Split(u.Path, "/") {
+			if seg == a.ID {
+				return true
+			}
+		}
+	}
+
+	// Localized slugs may contain non-ASCII characters, so match the
+	// decoded path, too.
+	return a.pattern != nil && (a.pattern.MatchString(u.String()) || a.pattern.MatchString(u.Path))
+}
+
+// ReleaseDate returns the date of the content update for the given patch
+// version.
+func (r *Registry) ReleaseDate(version string) (time.Time, bool) {
+	for _, a := range r.Articles {
+		if a.Kind == KindContentUpdate && a.Version == version {
+			return a.date, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Exit codes of the scrape command.
+const (
+	exitFailure = 1 // nothing has been written
+	exitPartial = 2 // output has been written, but some articles failed
+)
+
+// Stages of the scraping pipeline, as reported in ScrapeError.
+const (
+	StageCollect  = "collect"
+	StageFetch    = "fetch"
+	StageScrape   = "scrape"
+	StageCasing   = "casing"
+	StageRoute    = "route"
+	StageValidate = "validate"
+)
+
+// ScrapeError is an error that happened while processing a single article or
+// search page.
+type ScrapeError struct {
+	URL   string
+	Stage string
+	Err   error
+}
+
+func (e *ScrapeError) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.URL, e.Stage, e.Err)
+}
+
+func (e *ScrapeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ScrapeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		URL   string `json:",omitempty"`
+		Stage string
+		Error string
+	}{e.URL, e.Stage, e.Err.Error()})
+}
+
+// Report collects the errors of a single run.
+type Report struct {
+	Errors []*ScrapeError
+}
+
+func (r *Report) Add(u, stage string, err error) {
+	e := &ScrapeError{URL: u, Stage: stage, Err: err}
+	log.Println(e)
+	r.Errors = append(r.Errors, e)
+}
+
+// AddAll adds err to the report. If err wraps multiple errors (see
+// errors.Join), each ScrapeError among them is added separately.
+func (r *Report) AddAll(err error) {
+	var errs []error
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = u.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+
+	for _, err := range errs {
+		var e *ScrapeError
+		if errors.As(err, &e) {
+			r.Add(e.URL, e.Stage, e.Err)
+		} else {
+			r.Add("", "", err)
+		}
+	}
+}
+
+// URLs returns the set of URLs that have at least one error.
+func (r *Report) URLs() map[string]bool {
+	urls := map[string]bool{}
+	for _, e := range r.Errors {
+		if e.URL != "" {
+			urls[e.URL] = true
+		}
+	}
+	return urls
+}
+
+// Write writes the report as JSON to fname. It does nothing if fname is
+// empty.
+func (r *Report) Write(fname string) {
+	if fname == "" {
+		return
+	}
+
+	b, _ := json.MarshalIndent(r, "", "  ")
+	if err := os.WriteFile(fname, append(b, '\n'), 0o644); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// robotsAgent is the product token matched against User-agent lines in
+// robots.txt.
+var robotsAgent = strings.SplitN(userAgent, "/", 2)[0]
+
+type robotsRules struct {
+	rules []robotsRule
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int // length of the original pattern, for precedence
+	pattern *regexp.Regexp
+}
+
+// allowed reports whether robots.txt of u's host permits fetching u. The rules
+// of each host are fetched once.
+func (f *Fetcher) allowed(ctx context.Context, u string) (bool, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	rules, ok := f.robots[pu.Host]
+	f.mu.Unlock()
+
+	if !ok {
+		robotsURL := &url.URL{Scheme: pu.Scheme, Host: pu.Host, Path: "/robots.txt"}
+
+		p, err := f.fetchWithRetry(ctx, robotsURL.String(), nil)
+		var se *statusError
+		switch {
+		case err == nil:
+			rules = parseRobots(bytes.NewReader(p.Body), robotsAgent)
+		case errors.As(err, &se) && se.StatusCode >= 400 && se.StatusCode < 500:
+			// No robots.txt; everything is allowed.
+			rules = &robotsRules{}
+		default:
+			return false, err
+		}
+
+		f.mu.Lock()
+		if f.robots == nil {
+			f.robots = map[string]*robotsRules{}
+		}
+		f.robots[pu.Host] = rules
+		f.mu.Unlock()
+	}
+
+	path := pu.EscapedPath()
+	if pu.RawQuery != "" {
+		path += "?" + pu.RawQuery
+	}
+
+	return rules.Allowed(path), nil
+}
+
+// parseRobots parses the groups in robots.txt that apply to agent. Groups
+// naming agent explicitly take precedence over the "*" group.
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	var specific, wildcard []robotsRule
+	var hasSpecific bool
+
+	var inSpecific, inWildcard bool
+	var groupStarted bool // whether the current group has rules already
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if groupStarted {
+				inSpecific, inWildcard = false, false
+				groupStarted = false
+			}
+			switch {
+			case value == "*":
+				inWildcard = true
+			case strings.EqualFold(value, agent):
+				inSpecific = true
+				hasSpecific = true
+			}
+		case "allow", "disallow":
+			groupStarted = true
+			if value == "" {
+				continue
+			}
+
+			rule := robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			}
+			if inSpecific {
+				specific = append(specific, rule)
+			}
+			if inWildcard {
+				wildcard = append(wildcard, rule)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Println("robots.txt:", err)
+	}
+
+	if hasSpecific {
+		return &robotsRules{rules: specific}
+	}
+	return &robotsRules{rules: wildcard}
+}
+
+// robotsPattern translates a robots.txt path pattern, which may contain "*"
+// wildcards and a trailing "$", into a regular expression.
+func robotsPattern(s string) *regexp.Regexp {
+	anchored := strings.HasSuffix(s, "$")
+	s = strings.TrimSuffix(s, "$")
+
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(s), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+
+	return regexp.MustCompile(expr)
+}
+
+// Allowed reports whether path may be fetched. The longest matching rule
+// wins; on a tie, allow wins.
+func (r *robotsRules) Allowed(path string) bool {
+	allowed := true
+	best := -1
+
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			allowed = rule.allow
+			best = rule.length
+		}
+	}
+
+	return allowed
+}
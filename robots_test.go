@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRobotsAllowed(t *testing.T) {
+	const robots = `# comment
+User-agent: *
+Disallow: /
+
+User-agent: OtherBot
+User-agent: wow-patch-notes
+Disallow: /search
+Allow: /search/patch-notes
+Disallow: /*.json$
+Disallow: /private # trailing comment
+
+User-agent: OtherBot
+Allow: /
+`
+
+	rules := parseRobots(strings.NewReader(robots), "wow-patch-notes")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		// The specific group replaces the "*" group.
+		{"/en-us/news/24066687", true},
+		{"/search", false},
+		{"/search?q=hotfixes", false},
+		// The longest match wins.
+		{"/search/patch-notes", true},
+		{"/search/patch-notes/2", true},
+		// "$" anchors the pattern.
+		{"/notes.json", false},
+		{"/notes.json?v=2", true},
+		{"/private/x", false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Allowed(tt.path); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsGroups(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		path   string
+		want   bool
+	}{
+		{
+			name:   "wildcard group",
+			robots: "User-agent: *\nDisallow: /news\n",
+			path:   "/news/1",
+			want:   false,
+		},
+		{
+			name:   "other agent",
+			robots: "User-agent: OtherBot\nDisallow: /\n",
+			path:   "/news/1",
+			want:   true,
+		},
+		{
+			name:   "agent matched case-insensitively",
+			robots: "User-agent: *\nAllow: /\n\nUser-agent: WOW-PATCH-NOTES\nDisallow: /news\n",
+			path:   "/news/1",
+			want:   false,
+		},
+		{
+			name:   "tie goes to allow",
+			robots: "User-agent: *\nDisallow: /news\nAllow: /news\n",
+			path:   "/news/1",
+			want:   true,
+		},
+		{
+			name:   "empty disallow",
+			robots: "User-agent: *\nDisallow:\n",
+			path:   "/news/1",
+			want:   true,
+		},
+		{
+			name:   "wildcard in the middle",
+			robots: "User-agent: *\nDisallow: /*/news/*/comments\n",
+			path:   "/en-us/news/1/comments",
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		rules := parseRobots(strings.NewReader(tt.robots), "wow-patch-notes")
+		if got := rules.Allowed(tt.path); got != tt.want {
+			t.Errorf("%s: Allowed(%q) = %v, want %v", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFetcherRobots(t *testing.T) {
+	s := newTestServer(t,
+		respond(http.StatusOK, nil, "User-agent: *\nDisallow: /private\n"),
+		respond(http.StatusOK, nil, "page"),
+	)
+	f := &Fetcher{Mode: CacheOnline, Robots: true}
+
+	if _, err := f.Get(context.Background(), s.URL+"/private/1"); err == nil {
+		t.Error("disallowed page fetched")
+	}
+	if _, err := f.Get(context.Background(), s.URL+"/news/1"); err != nil {
+		t.Error(err)
+	}
+	if len(s.requests) != 2 || s.requests[0].URL.Path != "/robots.txt" {
+		t.Errorf("requests %v, want robots.txt once and the allowed page", s.requests)
+	}
+}
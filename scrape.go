@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// articleResult is the outcome of scraping a single article.
+type articleResult struct {
+	URL       string
+	Article   Article
+	Changes   []Change
+	Unchanged bool // the article hasn't changed since prev was written
+
+	Stage string // the failed stage if Err is not nil
+	Err   error
+}
+
+// scrapeArticles scrapes urls with the given number of concurrent workers.
+// Results are returned in the same order as urls.
+func scrapeArticles(ctx context.Context, f *Fetcher, registry *Registry, c Classifier, prev *PatchNotes, urls []string, workers int) []articleResult {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]articleResult, len(urls))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = scrapeArticle(ctx, f, registry, c, prev, urls[i])
+			}
+		}()
+	}
+
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func scrapeArticle(ctx context.Context, f *Fetcher, registry *Registry, c Classifier, prev *PatchNotes, u string) articleResult {
+	r := articleResult{URL: u}
+
+	doc, err := fetchDocument(ctx, f, u)
+	if err != nil {
+		r.Stage, r.Err = StageFetch, err
+		return r
+	}
+
+	r.Article = Article{URL: articleURL(doc.Url), Hash: contentHash(doc)}
+
+	if prev.hasArticle(r.Article) {
+		log.Println("unchanged:", r.Article.URL)
+		r.Unchanged = true
+		return r
+	}
+
+	r.Changes, err = scrapeDocument(nil, doc, registry, c)
+	if err != nil {
+		r.Stage, r.Err = StageScrape, err
+	}
+
+	return r
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// SiteDir is the set of patch notes files of one locale in the site
+// directory, one per patch as defined by the patch calendar:
+//
+//	wow-<key>-patch-notes.json         for the default locale
+//	wow-<key>-patch-notes.<locale>.json for all others
+type SiteDir struct {
+	Dir    string
+	Locale string
+
+	// Files maps site keys to the contents of their file.
+	Files map[string]*PatchNotes
+
+	orig map[string]*PatchNotes // all files as read
+}
+
+var siteFilePattern = regexp.MustCompile(`^wow-(.+?)-patch-notes(?:\.([a-z]{2}-[a-z]{2}))?\.json$`)
+
+func (s *SiteDir) fileName(key string) string {
+	name := "wow-" + key + "-patch-notes"
+	if s.Locale != defaultLocale {
+		name += "." + s.Locale
+	}
+	return filepath.Join(s.Dir, name+".json")
+}
+
+func readSiteDir(dir, locale string) (*SiteDir, error) {
+	s := &SiteDir{
+		Dir:    dir,
+		Locale: locale,
+		Files:  map[string]*PatchNotes{},
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		m := siteFilePattern.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		if m[2] != "" && m[2] != locale || m[2] == "" && locale != defaultLocale {
+			continue
+		}
+
+		notes, err := readPatchNotes(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		s.Files[m[1]] = notes
+	}
+
+	s.orig = map[string]*PatchNotes{}
+	for key, notes := range s.Files {
+		s.orig[key] = &PatchNotes{Articles: notes.Articles, Changes: notes.Changes}
+	}
+
+	return s, nil
+}
+
+// Union returns the articles and changes of all files.
+func (s *SiteDir) Union() *PatchNotes {
+	union := &PatchNotes{}
+	seen := map[string]bool{}
+
+	for _, key := range s.keys() {
+		notes := s.Files[key]
+		for _, a := range notes.Articles {
+			if !seen[a.URL] {
+				union.Articles = append(union.Articles, a)
+				seen[a.URL] = true
+			}
+		}
+		union.Changes = append(union.Changes, notes.Changes...)
+	}
+
+	return union
+}
+
+func (s *SiteDir) keys() []string {
+	keys := make([]string, 0, len(s.Files))
+	for k := range s.Files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Merge removes the changes of all scraped articles from all files and adds
+// changes to the files they belong to; see route. Changes of articles that
+// haven't been scraped again, or can't be routed, stay where they are.
+//
+// Each file lists the articles that it contains changes for. Articles without
+// any changes stay in the file they were listed in before. New ones are listed
+// in the file with the newest changes.
+func (s *SiteDir) Merge(calendar *Calendar, articles []Article, scraped map[string]bool, changes []Change) error {
+	hashes := map[string]Article{}
+	listedIn := map[string]string{}
+	for _, key := range s.keys() {
+		for _, a := range s.Files[key].Articles {
+			hashes[a.URL] = a
+			listedIn[a.URL] = key
+		}
+	}
+
+	// An article with changes that can't be routed keeps its previous
+	// changes and hash, so that it is scraped again next time.
+	var errs []error
+	failed := map[string]bool{}
+	patches := make([]*Patch, len(changes))
+	for i, c := range changes {
+		p, err := route(calendar, c)
+		if err != nil {
+			errs = append(errs, &ScrapeError{URL: c.URL, Stage: StageRoute, Err: err})
+			failed[c.URL] = true
+			continue
+		}
+		patches[i] = p
+	}
+
+	for _, a := range articles {
+		if !failed[a.URL] {
+			hashes[a.URL] = a
+		}
+	}
+
+	for _, notes := range s.Files {
+		var kept []Change
+		for _, c := range notes.Changes {
+			if !scraped[c.URL] || failed[c.URL] {
+				kept = append(kept, c)
+			}
+		}
+		notes.Changes = kept
+	}
+
+	for i, c := range changes {
+		if failed[c.URL] {
+			continue
+		}
+		key := patches[i].SiteKey()
+		s.file(key).Changes = append(s.file(key).Changes, c)
+	}
+
+	listed := map[string]bool{}
+	for _, notes := range s.Files {
+		notes.Articles = nil
+		for _, c := range notes.Changes {
+			listed[c.URL] = true
+			if a, ok := hashes[c.URL]; ok && !containsArticle(notes.Articles, c.URL) {
+				notes.Articles = append(notes.Articles, a)
+			}
+		}
+	}
+
+	var homeless []string
+	for u := range hashes {
+		if !listed[u] {
+			homeless = append(homeless, u)
+		}
+	}
+	sort.Strings(homeless)
+
+	newest := s.newestFile()
+	for _, u := range homeless {
+		key, ok := listedIn[u]
+		if !ok {
+			key = newest
+		}
+		// Without any changes at all, there is no file to list the article
+		// in, and it is scraped again next time.
+		if key != "" {
+			s.file(key).Articles = append(s.file(key).Articles, hashes[u])
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// route returns the patch whose file the change c belongs to. Content update
+// notes belong to the patch of their version, all other changes to the patch
+// whose site file takes the hotfixes of their day.
+func route(calendar *Calendar, c Change) (*Patch, error) {
+	version := c.Version
+	if version == "" {
+		version = versionTag(c.Tags)
+	}
+	if version != "" {
+		p, ok := calendar.Patch(version)
+		if !ok {
+			return nil, fmt.Errorf("no patch %s in the calendar", version)
+		}
+		return p, nil
+	}
+
+	date, err := time.Parse(time.DateOnly, c.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := calendar.SitePatchAt(date)
+	if !ok {
+		return nil, fmt.Errorf("no patch in the calendar for %s", c.Date)
+	}
+	return p, nil
+}
+
+// newestFile returns the key of the file with the newest change, or the empty
+// string if there are no changes.
+func (s *SiteDir) newestFile() string {
+	var key, date string
+	for _, k := range s.keys() {
+		for _, c := range s.Files[k].Changes {
+			if c.Date > date {
+				key, date = k, c.Date
+			}
+		}
+	}
+	return key
+}
+
+func containsArticle(articles []Article, u string) bool {
+	for _, a := range articles {
+		if a.URL == u {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SiteDir) file(key string) *PatchNotes {
+	notes, ok := s.Files[key]
+	if !ok {
+		notes = &PatchNotes{Changes: []Change{}}
+		s.Files[key] = notes
+	}
+	return notes
+}
+
+// Check returns an error if out refuses to write any of the files, or if
+// changes move between files and out doesn't allow it. Changes only move if
+// the patch calendar or the routing changes, so moves usually mean that
+// something went wrong.
+func (s *SiteDir) Check(out *Output) error {
+	if out.Force {
+		return nil
+	}
+
+	var errs []error
+	before, after := &PatchNotes{}, &PatchNotes{}
+	for _, key := range s.keys() {
+		old, ok := s.orig[key]
+		if !ok {
+			old = &PatchNotes{}
+		}
+		if err := out.shrinks(s.fileName(key), old, s.Files[key]); err != nil {
+			errs = append(errs, err)
+		}
+		before.Changes = append(before.Changes, old.Changes...)
+		after.Changes = append(after.Changes, s.Files[key].Changes...)
+	}
+
+	// Articles are validated across all files, so that changes moving
+	// between files aren't mistaken for changes the scraper lost.
+	errs = append(errs, validateNotes(before, after, out.MaxShrink)...)
+
+	if !out.AllowMoves {
+		errs = append(errs, s.moves()...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// moves returns an error for every pair of files that changes move between.
+func (s *SiteDir) moves() []error {
+	type change struct{ url, date, text string }
+	files := func(all map[string]*PatchNotes) map[change]map[string]bool {
+		m := map[change]map[string]bool{}
+		for key, notes := range all {
+			for _, c := range notes.Changes {
+				k := change{c.URL, c.Date, normalizeText(c.Text)}
+				if m[k] == nil {
+					m[k] = map[string]bool{}
+				}
+				m[k][key] = true
+			}
+		}
+		return m
+	}
+	before, after := files(s.orig), files(s.Files)
+
+	type move struct{ from, to string }
+	counts := map[move]int{}
+	for k, to := range after {
+		for from := range before[k] {
+			if to[from] {
+				continue
+			}
+			for key := range to {
+				if !before[k][key] {
+					counts[move{from, key}]++
+				}
+			}
+		}
+	}
+
+	moves := make([]move, 0, len(counts))
+	for m := range counts {
+		moves = append(moves, m)
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].from != moves[j].from {
+			return moves[i].from < moves[j].from
+		}
+		return moves[i].to < moves[j].to
+	})
+
+	var errs []error
+	for _, m := range moves {
+		errs = append(errs, &ScrapeError{Stage: StageRoute, Err: fmt.Errorf("%d changes move from %s to %s; use -allow-moves to move them",
+			counts[m], s.fileName(m.from), s.fileName(m.to))})
+	}
+	return errs
+}
+
+// Write writes all files whose content differs from what is on disk. It does
+// not call Check.
+func (s *SiteDir) Write(out *Output) error {
+	for _, key := range s.keys() {
+		if err := out.Write(s.fileName(key), s.Files[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
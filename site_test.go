@@ -0,0 +1,310 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	testHotfixes = "https://worldofwarcraft.blizzard.com/en-us/news/24057474"
+	testUpdate   = "https://worldofwarcraft.blizzard.com/en-us/news/24066682"
+	testOther    = "https://worldofwarcraft.blizzard.com/en-us/news/24066687"
+)
+
+// writeTestSite writes files to a temporary site directory and reads it.
+func writeTestSite(t *testing.T, files map[string]*PatchNotes) *SiteDir {
+	t.Helper()
+
+	dir := t.TempDir()
+	for key, notes := range files {
+		if err := writePatchNotes(filepath.Join(dir, "wow-"+key+"-patch-notes.json"), notes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := readSiteDir(dir, defaultLocale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRoute(t *testing.T) {
+	calendar := readTestCalendar(t)
+
+	tests := []struct {
+		name    string
+		change  Change
+		want    string // site key
+		wantErr bool
+	}{
+		{
+			name:   "content update by version",
+			change: Change{Date: "2024-04-19", Version: "10.2.7"},
+			want:   "10.3",
+		},
+		{
+			name:   "content update by version tag",
+			change: Change{Date: "2023-03-16", Tags: []string{"10.1.0"}},
+			want:   "10.1",
+		},
+		{
+			name:   "hotfix",
+			change: Change{Date: "2024-04-16"},
+			want:   "10.2",
+		},
+		{
+			name:   "hotfix after SiteFrom",
+			change: Change{Date: "2024-04-17"},
+			want:   "10.3",
+		},
+		{
+			name:    "unknown version",
+			change:  Change{Date: "2024-06-01", Version: "10.2.8"},
+			wantErr: true,
+		},
+		{
+			name:    "before the calendar",
+			change:  Change{Date: "2020-01-01"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid date",
+			change:  Change{Date: "yesterday"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		p, err := route(calendar, tt.change)
+		switch {
+		case tt.wantErr && err == nil:
+			t.Errorf("%s: routed to %s, want error", tt.name, p.SiteKey())
+		case !tt.wantErr && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !tt.wantErr && p.SiteKey() != tt.want:
+			t.Errorf("%s: routed to %s, want %s", tt.name, p.SiteKey(), tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	calendar := readTestCalendar(t)
+
+	// The existing files, as in site/: wow-10.3 holds the content update
+	// and some hotfixes.
+	files := func() map[string]*PatchNotes {
+		return map[string]*PatchNotes{
+			"10.2": {
+				Articles: []Article{{URL: testHotfixes, Hash: "h1"}},
+				Changes: []Change{
+					{URL: testHotfixes, Date: "2024-04-16", Text: "Old hotfix."},
+				},
+			},
+			"10.3": {
+				Articles: []Article{{URL: testUpdate, Hash: "u1"}, {URL: testOther, Hash: "o1"}},
+				Changes: []Change{
+					{URL: testUpdate, Date: "2024-04-19", Tags: []string{"10.2.7"}, Text: "Old note."},
+					{URL: testOther, Date: "2024-07-03", Text: "Other hotfix."},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		articles []Article
+		changes  []Change
+
+		want         map[string][]string // site key -> texts
+		wantArticles map[string][]string // site key -> URL#hash
+		wantErr      bool
+	}{
+		{
+			name:     "hotfixes by date",
+			articles: []Article{{URL: testHotfixes, Hash: "h2"}},
+			changes: []Change{
+				{URL: testHotfixes, Date: "2024-04-16", Text: "Old hotfix."},
+				{URL: testHotfixes, Date: "2024-04-17", Text: "New hotfix."},
+			},
+			want: map[string][]string{
+				"10.2": {"Old hotfix."},
+				"10.3": {"Old note.", "Other hotfix.", "New hotfix."},
+			},
+			wantArticles: map[string][]string{
+				"10.2": {testHotfixes + "#h2"},
+				"10.3": {testUpdate + "#u1", testOther + "#o1", testHotfixes + "#h2"},
+			},
+		},
+		{
+			name:     "content update by version",
+			articles: []Article{{URL: testUpdate, Hash: "u2"}},
+			changes: []Change{
+				{URL: testUpdate, Date: "2024-04-19", Tags: []string{"10.2.7"}, Version: "10.2.7", Text: "New note."},
+			},
+			want: map[string][]string{
+				"10.2": {"Old hotfix."},
+				"10.3": {"Other hotfix.", "New note."},
+			},
+			wantArticles: map[string][]string{
+				"10.2": {testHotfixes + "#h1"},
+				"10.3": {testOther + "#o1", testUpdate + "#u2"},
+			},
+		},
+		{
+			name:     "unroutable article keeps its changes and old hash",
+			articles: []Article{{URL: testUpdate, Hash: "u2"}},
+			changes: []Change{
+				{URL: testUpdate, Date: "2024-04-19", Tags: []string{"10.2.8"}, Version: "10.2.8", Text: "New note."},
+			},
+			want: map[string][]string{
+				"10.2": {"Old hotfix."},
+				"10.3": {"Old note.", "Other hotfix."},
+			},
+			wantArticles: map[string][]string{
+				"10.2": {testHotfixes + "#h1"},
+				"10.3": {testUpdate + "#u1", testOther + "#o1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "articles without changes",
+			articles: []Article{
+				{URL: testHotfixes, Hash: "h2"},
+				{URL: "https://worldofwarcraft.blizzard.com/en-us/news/1", Hash: "n1"},
+			},
+			want: map[string][]string{
+				"10.2": {},
+				"10.3": {"Old note.", "Other hotfix."},
+			},
+			wantArticles: map[string][]string{
+				"10.2": {testHotfixes + "#h2"},
+				"10.3": {testUpdate + "#u1", testOther + "#o1", "https://worldofwarcraft.blizzard.com/en-us/news/1#n1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := writeTestSite(t, files())
+
+			scraped := map[string]bool{}
+			for _, a := range tt.articles {
+				scraped[a.URL] = true
+			}
+
+			err := s.Merge(calendar, tt.articles, scraped, tt.changes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error: %v", err, tt.wantErr)
+			}
+
+			for key, want := range tt.want {
+				var got []string
+				for _, c := range s.Files[key].Changes {
+					got = append(got, c.Text)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("%s: changes %q, want %q", key, got, want)
+				}
+			}
+			for key, want := range tt.wantArticles {
+				var got []string
+				for _, a := range s.Files[key].Articles {
+					got = append(got, a.URL+"#"+a.Hash)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("%s: articles %q, want %q", key, got, want)
+				}
+			}
+			if len(s.Files) != len(tt.want) {
+				t.Errorf("files %q, want %d", s.keys(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMoves(t *testing.T) {
+	a := Change{URL: testHotfixes, Date: "2024-04-10", Text: "A."}
+	b := Change{URL: testHotfixes, Date: "2024-04-11", Text: "B."}
+
+	tests := []struct {
+		name   string
+		before map[string][]Change
+		after  map[string][]Change
+		want   []string // "from to" pairs
+	}{
+		{
+			name:   "unchanged",
+			before: map[string][]Change{"10.2": {a}, "10.3": {b}},
+			after:  map[string][]Change{"10.2": {a}, "10.3": {b}},
+		},
+		{
+			name:   "moved",
+			before: map[string][]Change{"10.2": {a}, "10.3": {b}},
+			after:  map[string][]Change{"10.2": {a, b}, "10.3": {}},
+			want:   []string{"10.3 10.2"},
+		},
+		{
+			name:   "case change only",
+			before: map[string][]Change{"10.3": {b}},
+			after:  map[string][]Change{"10.2": {{URL: b.URL, Date: b.Date, Text: "b."}}, "10.3": {}},
+			want:   []string{"10.3 10.2"},
+		},
+		{
+			name:   "duplicate dropped from one file",
+			before: map[string][]Change{"10.2": {a}, "10.3": {a}},
+			after:  map[string][]Change{"10.2": {a}, "10.3": {}},
+		},
+		{
+			name:   "new change",
+			before: map[string][]Change{"10.2": {a}},
+			after:  map[string][]Change{"10.2": {a}, "10.3": {b}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]*PatchNotes{}
+			for key, cs := range tt.before {
+				files[key] = &PatchNotes{Changes: cs}
+			}
+			s := writeTestSite(t, files)
+			s.Files = map[string]*PatchNotes{}
+			for key, cs := range tt.after {
+				s.Files[key] = &PatchNotes{Changes: cs}
+			}
+
+			errs := s.moves()
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors %v, want moves %q", errs, tt.want)
+			}
+			for i, w := range tt.want {
+				from, to, _ := strings.Cut(w, " ")
+				msg := errs[i].Error()
+				if !strings.Contains(msg, "from "+s.fileName(from)) || !strings.Contains(msg, "to "+s.fileName(to)) {
+					t.Errorf("error %q, want move from %s to %s", msg, from, to)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckAllowMoves(t *testing.T) {
+	a := Change{URL: testHotfixes, Date: "2024-04-17", Text: "A."}
+	s := writeTestSite(t, map[string]*PatchNotes{
+		"10.2": {Articles: []Article{{URL: testHotfixes}}, Changes: []Change{a}},
+		"10.3": {},
+	})
+	s.Files["10.2"] = &PatchNotes{}
+	s.Files["10.3"] = &PatchNotes{Articles: []Article{{URL: testHotfixes}}, Changes: []Change{a}}
+
+	if err := s.Check(&Output{MaxShrink: 1}); err == nil {
+		t.Error("moved article: no error")
+	}
+	if err := s.Check(&Output{MaxShrink: 1, AllowMoves: true}); err != nil {
+		t.Errorf("moved article with AllowMoves: %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -109,7 +112,10 @@ func cleanTag(t string) []string {
 	}
 }
 
-func fixCasing(changes []Change) {
+// fixCasing replaces upper case tags with their mixed case spelling. It
+// returns a ScrapeError for each article that still has upper case tags
+// afterwards.
+func fixCasing(changes []Change) error {
 	tagSet := map[string]string{ // upper -> mixed
 		"A SINGLE WING":                       "A Single Wing",
 		"BLACKSMITHING":                       "Blacksmithing",
@@ -168,7 +174,12 @@ func fixCasing(changes []Change) {
 		}
 	}
 
-	for _, t := range readTags() {
+	knownTags, err := readTags()
+	if err != nil {
+		return &ScrapeError{Stage: StageCasing, Err: err}
+	}
+
+	for _, t := range knownTags {
 		uc := strings.ToUpper(t)
 		if uc == t {
 			continue
@@ -177,7 +188,7 @@ func fixCasing(changes []Change) {
 		tagSet[uc] = t
 	}
 
-	var hasInvalidTag bool
+	var errs []error
 	for i, c := range changes {
 		for j, t := range c.Tags {
 			if mc, ok := tagSet[t]; ok {
@@ -188,29 +199,30 @@ func fixCasing(changes []Change) {
 					changes[i].Tags[j] = mc
 				}
 			} else if strings.IndexFunc(t, unicode.IsLetter) >= 0 && t == strings.ToUpper(t) {
-				hasInvalidTag = true
-				log.Println("Upper case tag: " + t)
+				errs = append(errs, &ScrapeError{
+					URL:   c.URL,
+					Stage: StageCasing,
+					Err:   fmt.Errorf("upper case tag: %s", t),
+				})
 			}
 		}
 	}
 
-	if hasInvalidTag {
-		log.Fatal("There is at least one invalid tag")
-	}
+	return errors.Join(errs...)
 }
 
-func readTags() []string {
+func readTags() ([]string, error) {
 	tags := map[string]struct{}{}
 
 	fnames, err := filepath.Glob("site/*.json")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	for _, fname := range fnames {
 		f, err := os.Open(fname)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
 		var old struct {
@@ -222,7 +234,7 @@ func readTags() []string {
 		err = json.NewDecoder(f).Decode(&old)
 		f.Close()
 		if err != nil && err != io.EOF {
-			log.Fatal(fname, err)
+			return nil, fmt.Errorf("%s: %w", fname, err)
 		}
 		for _, c := range old.Changes {
 			for _, t := range c.Tags {
@@ -231,7 +243,7 @@ func readTags() []string {
 		}
 	}
 
-	return maps.Keys(tags)
+	return maps.Keys(tags), nil
 }
 
 func checkTags(changes []Change) {
@@ -255,3 +267,77 @@ func checkTags(changes []Change) {
 		}
 	}
 }
+
+func runTags(args []string) {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes tags [flags] [FILE...]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "List the tags of all changes in the patch notes files, most used first.")
+		fmt.Fprintln(fs.Output(), "Defaults to site/*.json.")
+		fs.PrintDefaults()
+	}
+
+	var format string
+	var check bool
+	fs.StringVar(&format, "format", "text", "Output format; one of 'text', 'json'.")
+	fs.BoolVar(&check, "check", false, "Warn about tags that are a prefix of another tag.")
+
+	fs.Parse(args)
+
+	fnames := fs.Args()
+	if len(fnames) == 0 {
+		var err error
+		fnames, err = filepath.Glob("site/*.json")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var changes []Change
+	for _, fname := range fnames {
+		notes, err := readPatchNotes(fname)
+		if err != nil {
+			log.Fatalf("%s: %v", fname, err)
+		}
+		changes = append(changes, notes.Changes...)
+	}
+
+	if check {
+		checkTags(changes)
+	}
+
+	counts := map[string]int{}
+	for _, c := range changes {
+		for _, t := range c.Tags {
+			counts[t]++
+		}
+	}
+
+	type tagCount struct {
+		Tag   string
+		Count int
+	}
+	tags := make([]tagCount, 0, len(counts))
+	for t, n := range counts {
+		tags = append(tags, tagCount{t, n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	switch format {
+	case "json":
+		b, _ := json.MarshalIndent(struct{ Tags []tagCount }{tags}, "", "  ")
+		fmt.Println(string(b))
+	case "text":
+		for _, t := range tags {
+			fmt.Printf("%6d %s\n", t.Count, t.Tag)
+		}
+	default:
+		log.Fatalf("unknown format %q", format)
+	}
+}
@@ -3,8 +3,9 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"net/url"
+	"regexp"
 	"strings"
-	"time"
 
 	"golang.org/x/net/html"
 )
@@ -37,15 +38,49 @@ func (t TextType) MarshalText() ([]byte, error) {
 	return []byte(t.String()), nil
 }
 
+func (t *TextType) UnmarshalText(b []byte) error {
+	for _, typ := range []TextType{TypeUnclassified, TypeDate, TypeTag, TypeChange} {
+		if typ.String() == string(b) {
+			*t = typ
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown text type %q", b)
+}
+
 type Tree struct {
-	Text     string   `json:",omitempty"`
-	Type     TextType `json:",omitempty"`
-	Children []*Tree  `json:",omitempty"`
+	Text string `json:",omitempty"`
+	// HTML is Text with its inline formatting, and Links are the links in
+	// it; see richHTML and collectLinks.
+	HTML  string   `json:",omitempty"`
+	Links []Link   `json:",omitempty"`
+	Type  TextType `json:",omitempty"`
+	// Depth is the number of lists the text is nested in.
+	Depth int `json:",omitempty"`
+	// Rule describes the classifier rule that decided Type, and Confidence
+	// how sure the classifier is about it.
+	Rule       string  `json:",omitempty"`
+	Confidence float64 `json:",omitempty"`
+	Children   []*Tree `json:",omitempty"`
 }
 
-func CollectTexts(node *html.Node) []*Tree {
+// Link is a link in the text of a change.
+type Link struct {
+	Text string
+	URL  string
+	// Entity identifies the game object of a Wowhead link, e.g. "spell=12345".
+	Entity string `json:",omitempty"`
+}
+
+// CollectTexts builds the tree of texts below node. Relative links are
+// resolved against base, if it is not nil.
+func CollectTexts(node *html.Node, base *url.URL) []*Tree {
+	return collectTexts(node, base, 0)
+}
+
+func collectTexts(node *html.Node, base *url.URL, depth int) []*Tree {
 	var subTrees []*Tree
-	tree := &Tree{}
+	tree := &Tree{Depth: depth}
 
 	for n := node.FirstChild; n != nil; n = n.NextSibling {
 		if n.Type != html.ElementNode || n.Data != "div" {
@@ -62,16 +97,22 @@ func CollectTexts(node *html.Node) []*Tree {
 	for n := node.FirstChild; n != nil; n = n.NextSibling {
 		if isInline(n) {
 			tree.Text += text(n)
+			tree.HTML += richHTML(n, base)
+			tree.Links = append(tree.Links, collectLinks(n, base)...)
 			continue
 		}
 
 		if tree.Text != "" {
 			subTrees = append(subTrees, tree)
 		}
+		d := depth
+		if n.Type == html.ElementNode && (n.Data == "ul" || n.Data == "ol") {
+			d++
+		}
 		subTrees = append(subTrees, &Tree{
-			Children: CollectTexts(n),
+			Children: collectTexts(n, base, d),
 		})
-		tree = &Tree{}
+		tree = &Tree{Depth: depth}
 	}
 	if tree.Text != "" {
 		subTrees = append(subTrees, tree)
@@ -111,6 +152,106 @@ func text(node *html.Node) string {
 	return buf.String()
 }
 
+// richHTML returns the HTML of node, keeping only the inline elements isInline
+// recognizes and the href of links. Everything else is reduced to its text,
+// so the result is safe to embed in a page.
+func richHTML(node *html.Node, base *url.URL) string {
+	var buf bytes.Buffer
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			buf.WriteString(htmlEscaper.Replace(n.Data))
+			return
+		case n.Type != html.ElementNode:
+			return
+		case !isInline(n):
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				f(c)
+			}
+			return
+		}
+
+		buf.WriteString("<" + n.Data)
+		if href := linkURL(n, base); href != "" {
+			fmt.Fprintf(&buf, ` href="%s"`, htmlEscaper.Replace(href))
+		}
+		buf.WriteString(">")
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+		buf.WriteString("</" + n.Data + ">")
+	}
+	f(node)
+
+	return buf.String()
+}
+
+// htmlEscaper escapes only the characters that must be escaped in text and
+// quoted attributes, which keeps plain text unchanged.
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;")
+
+// collectLinks returns all links in node.
+func collectLinks(node *html.Node, base *url.URL) []Link {
+	var links []Link
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if href := linkURL(n, base); href != "" {
+			links = append(links, Link{
+				Text:   strings.TrimSpace(text(n)),
+				URL:    href,
+				Entity: wowheadEntity(href),
+			})
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(node)
+
+	return links
+}
+
+// linkURL returns the absolute URL n links to, if n is a link to a web page.
+func linkURL(n *html.Node, base *url.URL) string {
+	if n.Type != html.ElementNode || n.Data != "a" {
+		return ""
+	}
+
+	for _, a := range n.Attr {
+		if a.Key != "href" {
+			continue
+		}
+		u, err := url.Parse(strings.TrimSpace(a.Val))
+		if err != nil {
+			return ""
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return ""
+		}
+		return u.String()
+	}
+
+	return ""
+}
+
+// wowheadPattern matches links to game objects on Wowhead, e.g.
+// https://www.wowhead.com/spell=12345/fireball.
+var wowheadPattern = regexp.MustCompile(`^https?://(?:[a-z]+\.)?wowhead\.com/(?:[a-z-]+/)?([a-z-]+=\d+)`)
+
+func wowheadEntity(href string) string {
+	if m := wowheadPattern.FindStringSubmatch(href); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
 func (t *Tree) Prune(joinText bool) {
 	var cs []*Tree
 	for _, c := range t.Children {
@@ -127,6 +268,8 @@ func (t *Tree) Prune(joinText bool) {
 		n := len(cs)
 		if joinText && c.Text != "" && n > 0 && cs[n-1].Text != "" {
 			cs[n-1].Text += c.Text
+			cs[n-1].HTML += c.HTML
+			cs[n-1].Links = append(cs[n-1].Links, c.Links...)
 			continue
 		}
 
@@ -143,67 +286,51 @@ func (t *Tree) Walk(f func(n *Tree)) {
 	}
 }
 
-func Classify(t *Tree) TextType {
+// HeuristicClassifier classifies by length and punctuation. It is the default
+// Classifier. Its confidences are hand-picked constants that only order the
+// rules from the most to the least reliable; they are not measured.
+type HeuristicClassifier struct{}
+
+// ruleDefault is the rule of the HeuristicClassifier for texts that none of
+// its heuristics apply to.
+const ruleDefault = "default"
+
+func (HeuristicClassifier) Classify(t *Tree, loc *Locale) Classification {
 	if len(t.Children) > 0 {
-		return TypeUnclassified
+		return Classification{TypeUnclassified, "has children", 1}
 	}
 
 	// Both tags and dates are shorter than 50 bytes.
 	if len(t.Text) >= 50 {
-		return TypeChange
+		return Classification{TypeChange, "50 bytes or longer", 0.95}
+	}
+
+	// Some locales write dates with a full stop, e.g. "3. Juli 2024".
+	_, err := loc.ParseDate(t.Text)
+	if err == nil {
+		return Classification{TypeDate, "parses as date", 1}
 	}
 
 	// Tags never seem to contain a full stop, but 99% of all change notes are
 	// written as complete sentences...
 	if strings.Contains(t.Text, ".") {
-		return TypeChange
+		return Classification{TypeChange, `contains "."`, 0.9}
 	}
 
 	// ... and the few that are not happen to contain some sort of change
 	// measured in percent.
 	if strings.Contains(t.Text, "%") {
-		return TypeChange
+		return Classification{TypeChange, `contains "%"`, 0.8}
 	}
 	if strings.Contains(t.Text, "%") {
-		return TypeChange
+		return Classification{TypeChange, `contains "%"`, 0.8}
 	}
 	if strings.HasSuffix(t.Text, ":") {
-		return TypeChange
+		return Classification{TypeChange, `ends with ":"`, 0.7}
 	}
 	if strings.Contains(t.Text, "/ping") {
-		return TypeChange
-	}
-
-	_, err := time.Parse("January 2, 2006", t.Text)
-	if err == nil {
-		return TypeDate
+		return Classification{TypeChange, `contains "/ping"`, 0.8}
 	}
 
-	// if changePattern.MatchString(s) {
-	// 	return TypeChange
-	// }
-
-	return TypeTag
+	return Classification{TypeTag, ruleDefault, 0.6}
 }
-
-// var changePattern *regexp.Regexp
-
-// func init() {
-// 	phrases := []string{
-// 		"(fixed|addresse[sd]|resolve[sd]) (an?|some) (issue|bug|error)s?",
-// 		"(visual|audio) (issue|error)s?",
-// 		"(will|is|may|are|cat) now",
-// 		"no longer", "now causes", "now triggers", "should again",
-// 		"adjusted", "increase[sd]", "decrease[sd]", "reduce[sd]",
-// 		"properly", "correctly",
-// 		"not apply", "this change",
-// 		"in pvp combat",
-// 		"yards", "seconds",
-// 		"damage done", "cooldown", "health", "base mana", "stacks up to", "absorb shield",
-// 		"developers['’]? notes?",
-// 		"now spawn",
-// 	}
-
-// 	changePattern = regexp.MustCompile(fmt.Sprintf(`\b(%s)\b`,
-// 		strings.Join(phrases, "|")))
-// }
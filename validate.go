@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wow-patch-notes validate [flags] OLD NEW")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Check that NEW didn't lose a suspicious amount of the changes in OLD.")
+		fmt.Fprintln(fs.Output(), "Exits with status 1 if it did.")
+		fs.PrintDefaults()
+	}
+	out := &Output{}
+	fs.Float64Var(&out.MaxShrink, "max-shrink", 0.5,
+		"Fail if the file or an article loses more than this fraction of its changes.")
+
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	old, err := readPatchNotes(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	notes, err := readPatchNotes(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := out.check(fs.Arg(1), old, notes); err != nil {
+		report := &Report{}
+		report.AddAll(err)
+		log.Printf("%d errors", len(report.Errors))
+		os.Exit(exitFailure)
+	}
+}
+
+// validateNotes compares the changes of every article in old and notes with
+// the changes it had in old. An article that now yields no changes, or loses
+// more than maxShrink of them, usually means that the markup of the article
+// changed in a way the scraper doesn't understand, or that it is no longer
+// found at all. The returned errors list the dates and tags that lost the
+// most changes.
+func validateNotes(old, notes *PatchNotes, maxShrink float64) []error {
+	oldChanges := groupByURL(old.Changes)
+	newChanges := groupByURL(notes.Changes)
+
+	urls := map[string]bool{}
+	for url := range oldChanges {
+		urls[url] = true
+	}
+	for _, a := range notes.Articles {
+		urls[a.URL] = true
+	}
+	sorted := make([]string, 0, len(urls))
+	for url := range urls {
+		sorted = append(sorted, url)
+	}
+	sort.Strings(sorted)
+
+	var errs []error
+	for _, url := range sorted {
+		before, after := oldChanges[url], newChanges[url]
+		n, m := len(before), len(after)
+		if n == 0 || m > 0 && float64(n-m) <= maxShrink*float64(n) {
+			continue
+		}
+
+		msg := fmt.Sprintf("%d → %d changes", n, m)
+		if lost := countLosses(before, after, func(c Change) []string { return []string{c.Date} }); lost != "" {
+			msg += "; dates: " + lost
+		}
+		if lost := countLosses(before, after, func(c Change) []string { return c.Tags }); lost != "" {
+			msg += "; tags: " + lost
+		}
+
+		errs = append(errs, &ScrapeError{URL: url, Stage: StageValidate, Err: errors.New(msg)})
+	}
+
+	return errs
+}
+
+func groupByURL(changes []Change) map[string][]Change {
+	m := map[string][]Change{}
+	for _, c := range changes {
+		m[c.URL] = append(m[c.URL], c)
+	}
+	return m
+}
+
+// countLosses counts before and after by the keys returned by key and
+// describes the five keys that lost the most changes, e.g.
+// "2024-07-03 12 → 0, 2024-07-02 4 → 1".
+func countLosses(before, after []Change, key func(Change) []string) string {
+	count := func(cs []Change) map[string]int {
+		m := map[string]int{}
+		for _, c := range cs {
+			for _, k := range key(c) {
+				m[k]++
+			}
+		}
+		return m
+	}
+	b, a := count(before), count(after)
+
+	var keys []string
+	for k := range b {
+		if a[k] < b[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		li, lj := b[keys[i]]-a[keys[i]], b[keys[j]]-a[keys[j]]
+		if li != lj {
+			return li > lj
+		}
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) > 5 {
+		keys = keys[:5]
+	}
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d → %d", k, b[k], a[k])
+	}
+	return strings.Join(parts, ", ")
+}
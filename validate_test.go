@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateNotes(t *testing.T) {
+	changes := func(url string, n int) []Change {
+		cs := make([]Change, n)
+		for i := range cs {
+			cs[i] = Change{URL: url, Date: "2024-04-16", Tags: []string{"Classes"}}
+		}
+		return cs
+	}
+	join := func(css ...[]Change) []Change {
+		var all []Change
+		for _, cs := range css {
+			all = append(all, cs...)
+		}
+		return all
+	}
+
+	old := &PatchNotes{Changes: join(changes(testHotfixes, 10), changes(testUpdate, 10))}
+
+	tests := []struct {
+		name  string
+		notes *PatchNotes
+		want  []string // URLs with errors
+	}{
+		{
+			name:  "unchanged",
+			notes: &PatchNotes{Changes: join(changes(testHotfixes, 10), changes(testUpdate, 10))},
+		},
+		{
+			name:  "shrinks within limit",
+			notes: &PatchNotes{Changes: join(changes(testHotfixes, 5), changes(testUpdate, 10))},
+		},
+		{
+			name:  "shrinks too much",
+			notes: &PatchNotes{Changes: join(changes(testHotfixes, 4), changes(testUpdate, 10))},
+			want:  []string{testHotfixes},
+		},
+		{
+			name: "listed without changes",
+			notes: &PatchNotes{
+				Articles: []Article{{URL: testHotfixes}},
+				Changes:  changes(testUpdate, 10),
+			},
+			want: []string{testHotfixes},
+		},
+		{
+			name:  "no longer listed",
+			notes: &PatchNotes{Changes: changes(testHotfixes, 10)},
+			want:  []string{testUpdate},
+		},
+		{
+			name: "new article",
+			notes: &PatchNotes{
+				Articles: []Article{{URL: testOther}},
+				Changes:  join(old.Changes, changes(testOther, 3)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		errs := validateNotes(old, tt.notes, 0.5)
+		if len(errs) != len(tt.want) {
+			t.Errorf("%s: errors %v, want %d", tt.name, errs, len(tt.want))
+			continue
+		}
+		for i, url := range tt.want {
+			if !strings.Contains(errs[i].Error(), url) {
+				t.Errorf("%s: error %q, want %s", tt.name, errs[i], url)
+			}
+		}
+	}
+}